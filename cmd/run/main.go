@@ -8,13 +8,17 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/nxtcoder17/go.pkgs/log"
 	"github.com/nxtcoder17/runfile/errors"
+	"github.com/nxtcoder17/runfile/graph"
 	"github.com/nxtcoder17/runfile/runner"
+	"github.com/nxtcoder17/runfile/terminal"
 	"github.com/nxtcoder17/runfile/types"
 
 	"github.com/nxtcoder17/runfile/parser"
@@ -42,6 +46,12 @@ var shellCompletionZSH string
 var shellCompletionPS string
 
 func main() {
+	// INFO: everything after `--` is forwarded to tasks, and not parsed by cli
+	cliArgs, passArgs := os.Args, []string(nil)
+	if idx := slices.Index(os.Args, "--"); idx != -1 {
+		cliArgs, passArgs = os.Args[:idx], os.Args[idx+1:]
+	}
+
 	cmd := cli.Command{
 		Name:        "run",
 		Version:     Version,
@@ -66,6 +76,100 @@ func main() {
 				Value:   false,
 			},
 
+			&cli.StringSliceFlag{
+				Name:  "watch-path",
+				Usage: "in watch mode, watches this path too, can be repeated",
+			},
+
+			&cli.StringSliceFlag{
+				Name:  "watch-ext",
+				Usage: "in watch mode, watches only files with these extensions, overriding the task's, can be repeated",
+			},
+
+			&cli.StringSliceFlag{
+				Name:  "watch-ignore",
+				Usage: "in watch mode, ignores this path, can be repeated",
+			},
+
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "prints what would be executed, without running anything",
+				Value: false,
+			},
+
+			&cli.BoolFlag{
+				Name:  "eval-env",
+				Usage: "evaluates env vars with `sh` key, in dry run",
+				Value: false,
+			},
+
+			&cli.IntFlag{
+				Name:    "max-parallel",
+				Aliases: []string{"j"},
+				Usage:   "limits the number of commands running at once, 0 means no limit",
+				Value:   0,
+			},
+
+			&cli.BoolFlag{
+				Name:  "fail-fast",
+				Usage: "in parallel mode, cancels other tasks as soon as one fails, set it to false to wait for all of them",
+				Value: true,
+			},
+
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "how output of tasks is shown, one of [prefixed,grouped,raw,quiet]",
+				Value: string(types.OutputPrefixed),
+			},
+
+			&cli.BoolFlag{
+				Name:  "tty",
+				Usage: "runs commands in a pseudo terminal (linux only), so that tools keep their colors and progress output",
+			},
+
+			&cli.StringFlag{
+				Name:    "timestamps",
+				Usage:   "timestamps every line of output, one of [absolute,relative]",
+				Sources: cli.EnvVars("RUNFILE_TIMESTAMPS"),
+			},
+
+			&cli.StringFlag{
+				Name:    "echo",
+				Usage:   "how commands are shown before they run, one of [box,line,none]",
+				Value:   string(types.EchoBox),
+				Sources: cli.EnvVars("RUNFILE_ECHO"),
+			},
+
+			&cli.StringFlag{
+				Name:    "color",
+				Usage:   "when to color output, one of [auto,always,never]",
+				Value:   string(terminal.ColorAuto),
+				Sources: cli.EnvVars("RUNFILE_COLOR"),
+			},
+
+			&cli.StringFlag{
+				Name:    "theme",
+				Usage:   "chroma theme, to highlight commands with (see https://swapoff.org/chroma/playground/)",
+				Sources: cli.EnvVars("RUNFILE_THEME"),
+			},
+
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "writes report of the run to --report-file, one of [json,junit]",
+			},
+
+			&cli.StringFlag{
+				Name:      "report-file",
+				Usage:     "file to write report to, defaults to runfile-report.json or runfile-report.xml",
+				TakesFile: true,
+			},
+
+			&cli.BoolFlag{
+				Name:  "keep-going",
+				Usage: "keeps running the remaining tasks, even after one of them fails",
+				Value: false,
+			},
+
 			&cli.BoolFlag{
 				Name:  "debug",
 				Value: false,
@@ -109,7 +213,126 @@ func main() {
 			generateShellCompletion(ctx, c.Root().Writer, runfilePath)
 		},
 
+		// INFO: subcommand names are not reserved in Runfiles, a task with the same name runs instead of the subcommand
+		Before: func(ctx context.Context, c *cli.Command) (context.Context, error) {
+			name := c.Args().First()
+
+			var shadowed bool
+			switch name {
+			case "graph":
+				shadowed = hasTask(ctx, c, name)
+			case "watch":
+				// INFO: watch subcommand only explains, so without --explain, it is run as a task as well
+				explain := slices.ContainsFunc(c.Args().Slice(), func(arg string) bool {
+					return arg == "--explain" || arg == "-explain" || strings.HasPrefix(arg, "--explain=") || strings.HasPrefix(arg, "-explain=")
+				})
+				shadowed = !explain || hasTask(ctx, c, name)
+			}
+
+			if shadowed {
+				c.Commands = slices.DeleteFunc(c.Commands, func(sc *cli.Command) bool { return sc.Name == name })
+			}
+			return ctx, nil
+		},
+
 		Commands: []*cli.Command{
+			{
+				Name:      "graph",
+				Usage:     "visualizes tasks, and their run targets",
+				ArgsUsage: "[task]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "one of [text,dot,mermaid]",
+						Value: "text",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					runfilePath, err := locateRunfile(c)
+					if err != nil {
+						return err
+					}
+
+					// INFO: graph only needs tasks, so nothing needs to be evaluated
+					runfileCtx := types.NewContext(ctx, newLogger(false))
+					runfileCtx.DryRun = true
+
+					rf, err := parser.ParseRunfile(runfileCtx, runfilePath)
+					if err != nil {
+						return err
+					}
+
+					g, err := graph.Build(rf, c.Args().Slice()...)
+					if err != nil {
+						return err
+					}
+
+					switch c.String("format") {
+					case "text":
+						fmt.Fprint(c.Writer, g.Text())
+					case "dot":
+						fmt.Fprint(c.Writer, g.DOT())
+					case "mermaid":
+						fmt.Fprint(c.Writer, g.Mermaid())
+					default:
+						return fmt.Errorf("invalid format (%s), must be one of [text,dot,mermaid]", c.String("format"))
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "watch",
+				Usage:     "explains, whether a change to a file reruns watched tasks",
+				ArgsUsage: "[task...] --explain <path>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "explain",
+						Usage: "path of the file, to explain",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if c.String("explain") == "" {
+						return fmt.Errorf("needs --explain <path>")
+					}
+
+					runfilePath, err := locateRunfile(c)
+					if err != nil {
+						return err
+					}
+
+					// INFO: like graph, watch settings do not need anything to be evaluated
+					runfileCtx := types.NewContext(ctx, newLogger(false))
+					runfileCtx.DryRun = true
+
+					rf, err := parser.ParseRunfile(runfileCtx, runfilePath)
+					if err != nil {
+						return err
+					}
+
+					result, err := runner.ExplainWatch(runfileCtx, rf, c.Args().Slice(), c.String("explain"))
+					if err != nil {
+						return err
+					}
+
+					if len(result) == 0 {
+						fmt.Fprintln(c.Writer, "no task is watched, pass task names to explain them as with `run -w`")
+						return nil
+					}
+
+					for _, r := range result {
+						verdict := "skips"
+						switch {
+						case len(r.Routes) > 0:
+							verdict = "runs " + strings.Join(r.Routes, ", ")
+						case r.Rerun:
+							verdict = "reruns"
+						}
+						fmt.Fprintf(c.Writer, "%s: %s, %s\n", r.Task, verdict, r.Reason)
+					}
+					return nil
+				},
+			},
 			{
 				Name:    "shell:completion",
 				Usage:   "<bash|zsh|fish|ps>",
@@ -140,7 +363,24 @@ func main() {
 		Action: func(ctx context.Context, c *cli.Command) error {
 			parallel := c.Bool("parallel")
 			watch := c.Bool("watch")
+			watchPaths := c.StringSlice("watch-path")
+			watchExts := c.StringSlice("watch-ext")
+			watchIgnore := c.StringSlice("watch-ignore")
 			debug := c.Bool("debug")
+			keepGoing := c.Bool("keep-going")
+			failFast := c.Bool("fail-fast")
+			failFastSet := c.IsSet("fail-fast")
+			tty := c.Bool("tty")
+			output := types.OutputMode(c.String("output"))
+			echo := types.EchoMode(c.String("echo"))
+			timestamps := types.TimestampMode(c.String("timestamps"))
+			colorMode := terminal.ColorMode(c.String("color"))
+			theme := c.String("theme")
+			reportFormat := c.String("report")
+			reportFile := c.String("report-file")
+			maxParallel := int(c.Int("max-parallel"))
+			dryRun := c.Bool("dry-run")
+			evalEnv := c.Bool("eval-env")
 
 			showList := c.Bool("list")
 			if showList {
@@ -161,7 +401,9 @@ func main() {
 
 			// INFO: for supporting flags that have been suffixed post arguments
 			args := make([]string, 0, len(c.Args().Slice()))
-			for _, arg := range c.Args().Slice() {
+			cargs := c.Args().Slice()
+			for i := 0; i < len(cargs); i++ {
+				arg := cargs[i]
 				if arg == "-p" || arg == "--parallel" {
 					parallel = true
 					continue
@@ -177,6 +419,99 @@ func main() {
 					continue
 				}
 
+				if arg == "--keep-going" {
+					keepGoing = true
+					continue
+				}
+
+				if arg == "--tty" {
+					tty = true
+					continue
+				}
+
+				if arg == "--dry-run" {
+					dryRun = true
+					continue
+				}
+
+				if arg == "--eval-env" {
+					evalEnv = true
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "-j", "--max-parallel"); ok {
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						return fmt.Errorf("invalid value for %s, must be a number", arg)
+					}
+					maxParallel = n
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--output"); ok {
+					output = types.OutputMode(v)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--watch-path"); ok {
+					watchPaths = append(watchPaths, strings.Split(v, ",")...)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--watch-ext"); ok {
+					watchExts = append(watchExts, strings.Split(v, ",")...)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--watch-ignore"); ok {
+					watchIgnore = append(watchIgnore, strings.Split(v, ",")...)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--timestamps"); ok {
+					timestamps = types.TimestampMode(v)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--echo"); ok {
+					echo = types.EchoMode(v)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--color"); ok {
+					colorMode = terminal.ColorMode(v)
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--theme"); ok {
+					theme = v
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--report"); ok {
+					reportFormat = v
+					continue
+				}
+
+				if v, ok := flagValue(cargs, &i, "--report-file"); ok {
+					reportFile = v
+					continue
+				}
+
+				if arg == "--fail-fast" {
+					failFast, failFastSet = true, true
+					continue
+				}
+
+				if v, ok := strings.CutPrefix(arg, "--fail-fast="); ok {
+					b, err := strconv.ParseBool(v)
+					if err != nil {
+						return fmt.Errorf("invalid value for --fail-fast, must be a boolean")
+					}
+					failFast, failFastSet = b, true
+					continue
+				}
+
 				sp := strings.SplitN(arg, "=", 2)
 				if len(sp) == 2 {
 					kv[sp[0]] = sp[1]
@@ -186,66 +521,166 @@ func main() {
 				args = append(args, arg)
 			}
 
-			if parallel && watch {
-				return fmt.Errorf("parallel and watch can't be set together")
+			if keepGoing {
+				if failFastSet && failFast {
+					return fmt.Errorf("--keep-going can not be used with --fail-fast")
+				}
+				// INFO: fail fast is on by default, but keep going must let other tasks run to completion
+				failFast = false
 			}
 
-			logger := log.New(log.Options{
-				ShowCaller:    true,
-				ShowLogLevel:  true,
-				ShowDebugLogs: debug,
-			})
+			if !colorMode.IsValid() {
+				return fmt.Errorf("invalid color (%s), must be one of [auto,always,never]", colorMode)
+			}
+
+			if theme != "" {
+				if err := terminal.ValidateTheme(theme); err != nil {
+					return err
+				}
+			}
+
+			// INFO: capabilities are detected once, so it must be configured before anything gets printed
+			terminal.Configure(terminal.Options{Color: colorMode, Theme: theme})
+
+			if !output.IsValid() {
+				return fmt.Errorf("invalid output (%s), must be one of [prefixed,grouped,raw,quiet]", output)
+			}
+
+			if !timestamps.IsValid() {
+				return fmt.Errorf("invalid timestamps (%s), must be one of [absolute,relative]", timestamps)
+			}
+
+			if !echo.IsValid() {
+				return fmt.Errorf("invalid echo (%s), must be one of [box,line,none]", echo)
+			}
+
+			if reportFormat != "" {
+				if !runner.IsValidReportFormat(reportFormat) {
+					return fmt.Errorf("invalid report format (%s), must be one of [json,junit]", reportFormat)
+				}
+
+				if reportFile == "" {
+					reportFile = "runfile-report.json"
+					if reportFormat == runner.ReportFormatJUnit {
+						reportFile = "runfile-report.xml"
+					}
+				}
+			}
+
+			logger := newLogger(debug)
 
 			runfilePath, err := locateRunfile(c)
 			if err != nil {
-				slog.Error("locating runfile, got", "err", err)
 				return err
 			}
 
 			runfileCtx := types.NewContext(ctx, logger)
+			runfileCtx.DryRun = dryRun
+			runfileCtx.EvalEnv = evalEnv
 
-			rf, err2 := parser.ParseRunfile(runfileCtx, runfilePath)
-			if err2 != nil {
-				slog.Error("parsing runfile, got", "err", err2)
-				panic(err2)
+			rf, err := parser.ParseRunfile(runfileCtx, runfilePath)
+			if err != nil {
+				return err
 			}
 
-			if err := runner.Run(runfileCtx, rf, runner.RunArgs{
+			return runner.Run(runfileCtx, rf, runner.RunArgs{
+				Args:              passArgs,
 				Tasks:             args,
 				ExecuteInParallel: parallel,
 				Watch:             watch,
+				WatchPaths:        watchPaths,
+				WatchExtensions:   watchExts,
+				WatchIgnore:       watchIgnore,
 				Debug:             debug,
 				KVs:               kv,
-			}); err != nil {
-				errm, ok := err.(*errors.Error)
-				slog.Debug("got", "err", err)
-				if ok {
-					if errm != nil {
-						// errm.Error()
-						// TODO: change it to a better logging
-						// slog.Error("got", "err", errm)
-						errm.Log()
-					}
-				} else {
-					slog.Error("got", "err", err)
-				}
-			}
-
-			return nil
+				KeepGoing:         keepGoing,
+				FailFast:          failFast,
+				Output:            output,
+				Echo:              echo,
+				Timestamps:        timestamps,
+				TTY:               tty,
+				ReportFormat:      reportFormat,
+				ReportFile:        reportFile,
+				MaxParallel:       maxParallel,
+			})
 		},
+
+		// INFO: errors are logged, and mapped to exit codes by main itself
+		ExitErrHandler: func(ctx context.Context, c *cli.Command, err error) {},
 	}
 
-	ctx, cf := signal.NotifyContext(context.TODO(), syscall.SIGINT, syscall.SIGTERM)
+	ctx, cf := context.WithCancel(context.TODO())
 	defer cf()
 
+	// INFO: first signal, asks running commands to shut down gracefully, and second one force kills them
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		<-ctx.Done()
+		<-sigCh
 		cf()
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "force killing running commands ...")
+		runner.KillProcessGroups()
 	}()
 
-	if err := cmd.Run(ctx, os.Args); err != nil {
-		slog.Error("while running cmd, got", "err", err)
+	if err := cmd.Run(ctx, cliArgs); err != nil {
+		exitCode := errors.ExitCode(err)
+		// INFO: user interrupted it, so there is nothing more to report
+		if exitCode != errors.ExitCodeInterrupted {
+			slog.Debug("while running cmd, got", "err", err)
+			if errm, ok := err.(*errors.Error); ok {
+				errm.Log()
+			} else {
+				slog.Error("while running cmd, got", "err", err)
+			}
+		}
+		os.Exit(exitCode)
+	}
+}
+
+// flagValue reads value of a flag named one of names, from either `--flag value` or `--flag=value` forms, at args[*i].
+// For the former, it advances *i past the value
+func flagValue(args []string, i *int, names ...string) (string, bool) {
+	arg := args[*i]
+	if slices.Contains(names, arg) && *i+1 < len(args) {
+		*i++
+		return args[*i], true
 	}
+
+	if k, v, ok := strings.Cut(arg, "="); ok && slices.Contains(names, k) {
+		return v, true
+	}
+
+	return "", false
+}
+
+// newLogger creates a logger, whose output is colored only when terminal capabilities allow it
+func newLogger(debug bool) log.Logger {
+	return log.New(log.Options{
+		Writer:        terminal.ColorWriter(os.Stderr),
+		ShowCaller:    true,
+		ShowLogLevel:  true,
+		ShowDebugLogs: debug,
+	})
+}
+
+// hasTask reports whether the nearest Runfile has a task named name
+func hasTask(ctx context.Context, c *cli.Command, name string) bool {
+	runfilePath, err := locateRunfile(c)
+	if err != nil {
+		return false
+	}
+
+	runfileCtx := types.NewContext(ctx, newLogger(false))
+	runfileCtx.DryRun = true
+
+	rf, err := parser.ParseRunfile(runfileCtx, runfilePath)
+	if err != nil {
+		return false
+	}
+
+	_, ok := rf.Tasks[name]
+	return ok
 }
 
 func locateRunfile(c *cli.Command) (string, error) {
@@ -276,6 +711,6 @@ func locateRunfile(c *cli.Command) (string, error) {
 			dir = filepath.Dir(dir)
 		}
 
-		return "", fmt.Errorf("failed to locate your nearest Runfile")
+		return "", errors.ErrRunfileNotFound
 	}
 }
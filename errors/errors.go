@@ -1,15 +1,21 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/exec"
 	"runtime"
+	"slices"
+	"syscall"
 
 	"github.com/nxtcoder17/runfile/types"
 )
 
+// Error is never modified in place, every method returns a new one. So, ERROR constants below
+// can be wrapped, and annotated any number of times, without piling up errors on each other
 type Error struct {
 	msg string
 
@@ -20,6 +26,9 @@ type Error struct {
 	traces []string
 
 	err error
+
+	// exitCode is what `run` exits with, when this error is encountered
+	exitCode int
 }
 
 func (e *Error) GetWrappedErrorString() string {
@@ -53,11 +62,30 @@ func (e *Error) Error() string {
 	// return fmt.Sprintf("%v {%#v}", e.err, e.kv)
 }
 
+// Unwrap allows errors.Is and errors.As to look into the wrapped error
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.kv = slices.Clone(e.kv)
+	c.traces = slices.Clone(e.traces)
+	return &c
+}
+
 func (e *Error) WithTaskName(tn string) *Error {
+	e = e.clone()
 	e.taskName = tn
 	return e
 }
 
+func (e *Error) WithExitCode(code int) *Error {
+	e = e.clone()
+	e.exitCode = code
+	return e
+}
+
 func (e *Error) WithCtx(ctx types.Context) *Error {
 	return e.WithTaskName(ctx.TaskName)
 }
@@ -67,7 +95,11 @@ func (e *Error) GetTaskName() string {
 }
 
 func (e *Error) Log() {
-	fmt.Fprintf(os.Stderr, "%s%s%s\n", types.GetErrorStyledPrefix(e.resolveTaskName()), e.msg, e.GetWrappedErrorString())
+	prefix := e.resolveTaskName()
+	if prefix == "" {
+		prefix = "run"
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", types.GetErrorStyledPrefix(prefix), e.msg, e.GetWrappedErrorString())
 	if os.Getenv("RUNFILE_DEBUG") == "true" {
 		e.InspectLog()
 	}
@@ -95,6 +127,7 @@ func Err(msg string) *Error {
 
 func (e *Error) Wrap(err error) *Error {
 	_, file, line, _ := runtime.Caller(1)
+	e = e.clone()
 	e.traces = append(e.traces, fmt.Sprintf("%s:%d", file, line))
 	if e.err != nil {
 		e.err = errors.Join(e.err, err)
@@ -105,10 +138,11 @@ func (e *Error) Wrap(err error) *Error {
 }
 
 func (e *Error) WrapStr(msg string) *Error {
+	e = e.clone()
 	if e.err != nil {
-		e.err = errors.Join(e.err, fmt.Errorf(msg))
+		e.err = errors.Join(e.err, errors.New(msg))
 	} else {
-		e.err = fmt.Errorf(msg)
+		e.err = errors.New(msg)
 	}
 	return e
 }
@@ -126,6 +160,7 @@ func (e *Error) KV(kv ...any) *Error {
 		return e
 	}
 	_, file, line, _ := runtime.Caller(1)
+	e = e.clone()
 	e.kv = append(e.kv, kv...)
 	e.traces = append(e.traces, fmt.Sprintf("%s:%d", file, line))
 
@@ -137,42 +172,139 @@ func WithErr(err error) *Error {
 	err2, ok := err.(*Error)
 	if !ok {
 		err2 = &Error{err: err}
+	} else {
+		err2 = err2.clone()
 	}
 
 	err2.traces = append(err2.traces, fmt.Sprintf("%s:%d", file, line))
 	return err2
 }
 
+/*
+Exit codes, that `run` exits with.
+
+When a command fails, `run` exits with the exit code of that command. Otherwise, it
+exits with one of the codes below, depending on the kind of error it encountered.
+*/
+const (
+	ExitCodeSuccess = 0
+
+	// ExitCodeGeneric is for errors, that do not have an exit code of their own
+	ExitCodeGeneric = 1
+
+	ExitCodeRunfileNotFound = 100
+
+	// ExitCodeRunfileParse is for errors, while reading or parsing runfile, its includes or dotenv files
+	ExitCodeRunfileParse = 101
+
+	// ExitCodeValidation is for invalid env vars, commands, working directories etc.
+	ExitCodeValidation = 102
+
+	ExitCodeTaskNotFound      = 103
+	ExitCodeRequirementNotMet = 104
+
+	ExitCodeTimeout     = 124
+	ExitCodeInterrupted = 130
+)
+
+// ExitCode maps an error to the exit code, `run` should exit with
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeSuccess
+	case errors.Is(err, context.Canceled):
+		return ExitCodeInterrupted
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitCodeTimeout
+	}
+
+	if code, ok := findExitCode(err); ok {
+		return code
+	}
+
+	return ExitCodeGeneric
+}
+
+// CommandExitCode is the exit code of a failed command. For commands killed by a signal,
+// it is 128 + signal number, like shells report them, e.g. 130 for SIGINT, and 143 for SIGTERM
+func CommandExitCode(e *exec.ExitError) int {
+	if ws, ok := e.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return e.ExitCode()
+}
+
+// findExitCode looks for the outermost error with an exit code, in the error tree
+func findExitCode(err error) (int, bool) {
+	switch e := err.(type) {
+	case *Error:
+		if e.exitCode != 0 {
+			return e.exitCode, true
+		}
+	case *exec.ExitError:
+		if code := CommandExitCode(e); code > 0 {
+			return code, true
+		}
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if w := u.Unwrap(); w != nil {
+			return findExitCode(w)
+		}
+	case interface{ Unwrap() []error }:
+		for _, w := range u.Unwrap() {
+			if code, ok := findExitCode(w); ok {
+				return code, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
 // ERROR constants
 var (
-	ErrReadRunfile  = Err("failed to read runfile")
-	ErrParseRunfile = Err("failed to read runfile")
+	ErrRunfileNotFound = Err("failed to locate your nearest Runfile").WithExitCode(ExitCodeRunfileNotFound)
+
+	ErrReadRunfile  = Err("failed to read runfile").WithExitCode(ExitCodeRunfileParse)
+	ErrParseRunfile = Err("failed to parse runfile").WithExitCode(ExitCodeRunfileParse)
 
-	ErrParseIncludes = Err("failed to parse includes")
-	ErrParseDotEnv   = Err("failed to parse dotenv file")
-	ErrInvalidDotEnv = Err("invalid dotenv file")
+	ErrParseIncludes = Err("failed to parse includes").WithExitCode(ExitCodeRunfileParse)
+	ErrParseDotEnv   = Err("failed to parse dotenv file").WithExitCode(ExitCodeRunfileParse)
+	ErrInvalidDotEnv = Err("invalid dotenv file").WithExitCode(ExitCodeRunfileParse)
 
 	ErrInvalidEnvVar = func(k string) *Error {
-		return Err(fmt.Sprintf("invalid env var (%s)", k))
+		return Err(fmt.Sprintf("invalid env var (%s)", k)).WithExitCode(ExitCodeValidation)
 	}
 
 	ErrRequiredEnvVar = func(k string) *Error {
-		return Err(fmt.Sprintf("required env var (%s)", k))
+		return Err(fmt.Sprintf("required env var (%s)", k)).WithExitCode(ExitCodeValidation)
 	}
 
 	ErrInvalidDefaultValue = func(k string, v any) *Error {
-		return Err(fmt.Sprintf("invalid default value for env var (%s),default: %v", k, v))
+		return Err(fmt.Sprintf("invalid default value for env var (%s),default: %v", k, v)).WithExitCode(ExitCodeValidation)
 	}
 
-	ErrEvalEnvVarSh = Err("failed while executing env-var sh script")
+	ErrEvalEnvVarSh = Err("failed while executing env-var sh script").WithExitCode(ExitCodeValidation)
 
-	ErrTaskNotFound          = Err("task not found")
+	ErrTaskNotFound          = Err("task not found").WithExitCode(ExitCodeTaskNotFound)
 	ErrTaskFailed            = Err("task failed")
-	ErrTaskParsingFailed     = Err("task parsing failed")
-	ErrTaskRequirementNotMet = Err("task requirements not met")
-	ErrTaskInvalidWorkingDir = Err("task invalid working directory")
-
-	ErrTaskInvalidCommand = Err("task invalid command")
+	ErrTaskParsingFailed     = Err("task parsing failed").WithExitCode(ExitCodeValidation)
+	ErrTaskRequirementNotMet = Err("task requirements not met").WithExitCode(ExitCodeRequirementNotMet)
+	ErrTaskInvalidWorkingDir = Err("task invalid working directory").WithExitCode(ExitCodeValidation)
+	ErrTaskLogFile           = Err("failed to open task log file")
+	ErrStdinFile             = Err("failed to open stdin file")
+
+	ErrTaskInvalidCommand = Err("task invalid command").WithExitCode(ExitCodeValidation)
+
+	ErrCommandFailed = func(exitCode int) *Error {
+		err := Err(fmt.Sprintf("command failed with exit code %d", exitCode))
+		if exitCode > 0 {
+			return err.WithExitCode(exitCode)
+		}
+		return err.WithExitCode(ExitCodeGeneric)
+	}
 )
 
-var ErrInvalidShellAlias error = Err("invalid shell alias")
+var ErrInvalidShellAlias error = Err("invalid shell alias").WithExitCode(ExitCodeValidation)
@@ -0,0 +1,103 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func Test_ExitCode(t *testing.T) {
+	exitErr := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		if err == nil {
+			t.Fatalf("command must fail with exit code %d", code)
+		}
+		return err
+	}
+
+	var killed *exec.ExitError
+	if !errors.As(exec.Command("sh", "-c", "kill -TERM $$").Run(), &killed) {
+		t.Fatalf("command must be killed by SIGTERM")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "1. no error, exits with success",
+			err:  nil,
+			want: ExitCodeSuccess,
+		},
+		{
+			name: "2. unknown error, exits with generic code",
+			err:  fmt.Errorf("something went wrong"),
+			want: ExitCodeGeneric,
+		},
+		{
+			name: "3. failing command, exits with its exit code",
+			err:  ErrCommandFailed(7).Wrap(exitErr(7)),
+			want: 7,
+		},
+		{
+			name: "4. failing command, wrapped again, still exits with its exit code",
+			err:  WithErr(ErrCommandFailed(3).Wrap(exitErr(3))).KV("task", "test"),
+			want: 3,
+		},
+		{
+			name: "5. failing requirement, exits with requirement code, not requirement command's exit code",
+			err:  Err("task requirements not met").WithExitCode(ExitCodeRequirementNotMet).Wrap(exitErr(2)),
+			want: ExitCodeRequirementNotMet,
+		},
+		{
+			name: "6. interrupted, exits with 130",
+			err:  WithErr(context.Canceled),
+			want: ExitCodeInterrupted,
+		},
+		{
+			name: "7. timed out, exits with 124",
+			err:  fmt.Errorf("task timed out: %w", context.DeadlineExceeded),
+			want: ExitCodeTimeout,
+		},
+		{
+			name: "8. joined errors, exits with code of the first one having it",
+			err:  errors.Join(fmt.Errorf("no code"), Err("task not found").WithExitCode(ExitCodeTaskNotFound)),
+			want: ExitCodeTaskNotFound,
+		},
+		{
+			name: "9. command killed by a signal, exits with 128 + signal",
+			err:  ErrCommandFailed(CommandExitCode(killed)).Wrap(killed),
+			want: 143,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ErrorConstantsAreNotModified(t *testing.T) {
+	sentinel := Err("task not found").WithExitCode(ExitCodeTaskNotFound)
+
+	a := sentinel.Wrap(fmt.Errorf("first")).KV("task", "a")
+	b := WithErr(sentinel.Wrap(fmt.Errorf("second"))).KV("task", "b")
+
+	if sentinel.err != nil || len(sentinel.kv) != 0 || len(sentinel.traces) != 1 {
+		t.Errorf("sentinel got modified, err = %v, kv = %v, traces = %v", sentinel.err, sentinel.kv, sentinel.traces)
+	}
+
+	if a.Error() != "first" || b.Error() != "second" {
+		t.Errorf("wrapped errors, got = (%q, %q), want = (%q, %q)", a.Error(), b.Error(), "first", "second")
+	}
+
+	if ExitCode(b) != ExitCodeTaskNotFound {
+		t.Errorf("ExitCode() = %v, want %v", ExitCode(b), ExitCodeTaskNotFound)
+	}
+}
@@ -6,13 +6,16 @@ toolchain go1.24.2
 
 require (
 	github.com/alecthomas/chroma/v2 v2.15.0
+	github.com/bmatcuk/doublestar/v4 v4.9.1
 	github.com/charmbracelet/lipgloss v1.0.0
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/joho/godotenv v1.5.1
 	github.com/muesli/termenv v0.15.2
-	github.com/nxtcoder17/fwatcher v1.2.2-0.20250318121757-bfc2065fa9f5
 	github.com/nxtcoder17/go.pkgs v0.0.0-20250216034729-39e2d2cd48da
+	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/urfave/cli/v3 v3.0.0-beta1
 	golang.org/x/sync v0.10.0
+	golang.org/x/sys v0.33.0
 	golang.org/x/term v0.32.0
 	sigs.k8s.io/yaml v1.4.0
 )
@@ -21,7 +24,6 @@ require (
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
 	github.com/charmbracelet/x/ansi v0.4.2 // indirect
 	github.com/dlclark/regexp2 v1.11.4 // indirect
-	github.com/fsnotify/fsnotify v1.8.0 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -32,6 +34,5 @@ require (
 	github.com/samber/lo v1.47.0 // indirect
 	github.com/samber/slog-common v0.18.1 // indirect
 	github.com/samber/slog-zerolog/v2 v2.7.3 // indirect
-	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 )
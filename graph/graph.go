@@ -0,0 +1,319 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nxtcoder17/runfile/errors"
+	"github.com/nxtcoder17/runfile/parser"
+	"github.com/nxtcoder17/runfile/types"
+)
+
+// Node is a task, along with the tasks it calls via `run`
+type Node struct {
+	Name      string
+	Namespace string
+
+	// Parallel is true, when run targets of this task are executed in parallel
+	Parallel bool
+
+	// Targets are the run targets, in the order they are called
+	Targets []string
+}
+
+// Graph is the graph of tasks, and their `run` targets
+type Graph struct {
+	Nodes map[string]*Node
+
+	// Roots are the tasks, graph is drawn from
+	Roots []string
+}
+
+// runTargets lists `run` targets from commands of a task, without parsing the task
+func runTargets(task types.Task) []string {
+	var targets []string
+	for _, cmd := range append(append([]any{}, task.Commands...), task.Finally...) {
+		m, ok := cmd.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if run, ok := m["run"].(string); ok {
+			targets = append(targets, parser.ResolveRunTarget(task.Metadata.Namespace, run))
+		}
+	}
+	return targets
+}
+
+// Build creates graph of tasks reachable from roots, or of all tasks, when no roots are given
+func Build(prf *types.ParsedRunfile, roots ...string) (*Graph, error) {
+	g := &Graph{Nodes: make(map[string]*Node), Roots: roots}
+
+	allTasks := len(roots) == 0
+	if allTasks {
+		for name := range prf.Tasks {
+			roots = append(roots, name)
+		}
+		slices.Sort(roots)
+	}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, ok := g.Nodes[name]; ok {
+			return nil
+		}
+
+		task, ok := prf.Tasks[name]
+		if !ok {
+			return errors.ErrTaskNotFound.Wrap(fmt.Errorf("task (%s) not found", name)).KV("task", name)
+		}
+
+		node := &Node{
+			Name:      name,
+			Namespace: task.Metadata.Namespace,
+			Parallel:  task.Parallel,
+			Targets:   runTargets(task),
+		}
+		g.Nodes[name] = node
+
+		for _, target := range node.Targets {
+			if err := visit(target); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, root := range roots {
+		if err := visit(root); err != nil {
+			return nil, err
+		}
+	}
+
+	if allTasks {
+		// INFO: when drawing all tasks, only the ones not called by any other task are roots
+		called := make(map[string]bool)
+		for _, node := range g.Nodes {
+			for _, target := range node.Targets {
+				called[target] = true
+			}
+		}
+
+		for _, name := range roots {
+			if !called[name] {
+				g.Roots = append(g.Roots, name)
+			}
+		}
+
+		if len(g.Roots) == 0 {
+			g.Roots = roots
+		}
+	}
+
+	return g, nil
+}
+
+// sortedNames returns names of all nodes, sorted
+func (g *Graph) sortedNames() []string {
+	names := make([]string, 0, len(g.Nodes))
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// namespaces returns all namespaces, with names of their nodes. Root runfile's namespace is ""
+func (g *Graph) namespaces() ([]string, map[string][]string) {
+	m := make(map[string][]string)
+	for _, name := range g.sortedNames() {
+		ns := g.Nodes[name].Namespace
+		m[ns] = append(m[ns], name)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys, m
+}
+
+// parallelMembers lists run targets of a parallel task, that can be drawn in a cluster with it,
+// i.e. the ones in the same namespace
+func (g *Graph) parallelMembers(node *Node, placed map[string]bool) []string {
+	var members []string
+	for _, target := range node.Targets {
+		if placed[target] || g.Nodes[target].Namespace != node.Namespace {
+			continue
+		}
+		placed[target] = true
+		members = append(members, target)
+	}
+	return members
+}
+
+func dotID(name string) string {
+	return fmt.Sprintf("%q", name)
+}
+
+// DOT renders graph in graphviz format, with namespaces and parallel groups as clusters
+func (g *Graph) DOT() string {
+	b := new(strings.Builder)
+	b.WriteString("digraph runfile {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	namespaces, members := g.namespaces()
+	placed := make(map[string]bool)
+
+	for _, ns := range namespaces {
+		indent := "  "
+		if ns != "" {
+			fmt.Fprintf(b, "  subgraph %s {\n", dotID("cluster_ns_"+ns))
+			fmt.Fprintf(b, "    label=%s;\n", dotID(ns))
+			indent = "    "
+		}
+
+		for _, name := range members[ns] {
+			node := g.Nodes[name]
+			if !node.Parallel || len(node.Targets) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(b, "%ssubgraph %s {\n", indent, dotID("cluster_parallel_"+name))
+			fmt.Fprintf(b, "%s  label=%s;\n", indent, dotID(name+" (parallel)"))
+			fmt.Fprintf(b, "%s  style=dashed;\n", indent)
+			for _, member := range g.parallelMembers(node, placed) {
+				fmt.Fprintf(b, "%s  %s;\n", indent, dotID(member))
+			}
+			fmt.Fprintf(b, "%s}\n", indent)
+		}
+
+		for _, name := range members[ns] {
+			if !placed[name] {
+				placed[name] = true
+				fmt.Fprintf(b, "%s%s;\n", indent, dotID(name))
+			}
+		}
+
+		if ns != "" {
+			b.WriteString("  }\n")
+		}
+	}
+
+	for _, name := range g.sortedNames() {
+		node := g.Nodes[name]
+		for _, target := range node.Targets {
+			attrs := ""
+			if node.Parallel {
+				attrs = " [style=dashed]"
+			}
+			fmt.Fprintf(b, "  %s -> %s%s;\n", dotID(name), dotID(target), attrs)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaidID makes task name usable as a mermaid node id
+func mermaidID(name string) string {
+	r := strings.NewReplacer(":", "_", "-", "_", ".", "_", " ", "_", "/", "_")
+	return "t_" + r.Replace(name)
+}
+
+// Mermaid renders graph as a mermaid flowchart, with namespaces and parallel groups as subgraphs
+func (g *Graph) Mermaid() string {
+	b := new(strings.Builder)
+	b.WriteString("flowchart LR\n")
+
+	namespaces, members := g.namespaces()
+	placed := make(map[string]bool)
+
+	for _, ns := range namespaces {
+		indent := "  "
+		if ns != "" {
+			fmt.Fprintf(b, "  subgraph %s [%s]\n", "ns_"+mermaidID(ns), ns)
+			indent = "    "
+		}
+
+		for _, name := range members[ns] {
+			node := g.Nodes[name]
+			if !node.Parallel || len(node.Targets) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(b, "%ssubgraph %s [\"%s (parallel)\"]\n", indent, "parallel_"+mermaidID(name), name)
+			for _, member := range g.parallelMembers(node, placed) {
+				fmt.Fprintf(b, "%s  %s[\"%s\"]\n", indent, mermaidID(member), member)
+			}
+			fmt.Fprintf(b, "%send\n", indent)
+		}
+
+		for _, name := range members[ns] {
+			if !placed[name] {
+				placed[name] = true
+				fmt.Fprintf(b, "%s%s[\"%s\"]\n", indent, mermaidID(name), name)
+			}
+		}
+
+		if ns != "" {
+			b.WriteString("  end\n")
+		}
+	}
+
+	for _, name := range g.sortedNames() {
+		node := g.Nodes[name]
+		arrow := "-->"
+		if node.Parallel {
+			arrow = "-.->"
+		}
+		for _, target := range node.Targets {
+			fmt.Fprintf(b, "  %s %s %s\n", mermaidID(name), arrow, mermaidID(target))
+		}
+	}
+
+	return b.String()
+}
+
+// Text renders graph as a tree, from each of its roots
+func (g *Graph) Text() string {
+	b := new(strings.Builder)
+
+	var walk func(name string, indent string, isLast bool, isRoot bool, trail []string)
+	walk = func(name string, indent string, isLast bool, isRoot bool, trail []string) {
+		node := g.Nodes[name]
+
+		branch, childIndent := "├── ", indent+"│   "
+		if isLast {
+			branch, childIndent = "└── ", indent+"    "
+		}
+		if isRoot {
+			branch, childIndent = "", ""
+		}
+
+		label := name
+		if node.Parallel && len(node.Targets) > 0 {
+			label += " (parallel)"
+		}
+
+		if slices.Contains(trail, name) {
+			fmt.Fprintf(b, "%s%s%s (cycle)\n", indent, branch, name)
+			return
+		}
+		fmt.Fprintf(b, "%s%s%s\n", indent, branch, label)
+
+		trail = append(trail, name)
+		for i, target := range node.Targets {
+			walk(target, childIndent, i == len(node.Targets)-1, false, trail)
+		}
+	}
+
+	for _, root := range g.Roots {
+		walk(root, "", true, true, nil)
+	}
+
+	return b.String()
+}
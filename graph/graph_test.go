@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nxtcoder17/runfile/types"
+)
+
+func testRunfile() *types.ParsedRunfile {
+	tasks := map[string]types.Task{
+		"dev": {
+			Parallel: true,
+			Commands: []any{
+				map[string]any{"run": "css"},
+				map[string]any{"run": "server"},
+			},
+		},
+		"css": {
+			Commands: []any{"tailwindcss -o dist/app.css"},
+		},
+		"server": {
+			Commands: []any{
+				map[string]any{"run": "db:up"},
+				"go run ./cmd/server",
+			},
+		},
+	}
+
+	up := types.Task{Commands: []any{"docker compose up -d"}}
+	up.Metadata.Namespace = "db"
+	tasks["db:up"] = up
+
+	return &types.ParsedRunfile{Tasks: tasks}
+}
+
+func Test_Build(t *testing.T) {
+	tests := []struct {
+		name      string
+		roots     []string
+		wantRoots []string
+		wantNodes int
+		wantErr   bool
+	}{
+		{
+			name:      "1. without roots, draws all tasks from the ones not called by others",
+			wantRoots: []string{"dev"},
+			wantNodes: 4,
+		},
+		{
+			name:      "2. with a root, draws only tasks reachable from it",
+			roots:     []string{"server"},
+			wantRoots: []string{"server"},
+			wantNodes: 2,
+		},
+		{
+			name:    "3. [unhappy] root task does not exist",
+			roots:   []string{"does-not-exist"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := Build(testRunfile(), tt.roots...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Build(), error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if strings.Join(g.Roots, ",") != strings.Join(tt.wantRoots, ",") {
+				t.Errorf("Build(), roots\n\tgot = %v\n\twant = %v", g.Roots, tt.wantRoots)
+			}
+
+			if len(g.Nodes) != tt.wantNodes {
+				t.Errorf("Build(), len(nodes)\n\tgot = %v\n\twant = %v", len(g.Nodes), tt.wantNodes)
+			}
+		})
+	}
+}
+
+func Test_Render(t *testing.T) {
+	g, err := Build(testRunfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		render func() string
+		want   []string
+	}{
+		{
+			name:   "1. text tree",
+			render: g.Text,
+			want: []string{
+				"dev (parallel)\n├── css\n└── server\n    └── db:up\n",
+			},
+		},
+		{
+			name:   "2. dot, with parallel group, and namespace as clusters",
+			render: g.DOT,
+			want: []string{
+				`subgraph "cluster_ns_db" {`,
+				`subgraph "cluster_parallel_dev" {`,
+				`"dev" -> "css" [style=dashed];`,
+				`"server" -> "db:up";`,
+			},
+		},
+		{
+			name:   "3. mermaid, with parallel group, and namespace as subgraphs",
+			render: g.Mermaid,
+			want: []string{
+				"subgraph ns_t_db [db]",
+				`subgraph parallel_t_dev ["dev (parallel)"]`,
+				"t_dev -.-> t_css",
+				"t_server --> t_db_up",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.render()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("render(), does not contain %q\n\tgot = %s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -8,6 +8,14 @@ import (
 	"github.com/nxtcoder17/runfile/types"
 )
 
+// ResolveRunTarget resolves `run` target of a task, that is included under a namespace
+func ResolveRunTarget(namespace string, target string) string {
+	if namespace == "" {
+		return target
+	}
+	return namespace + ":" + target
+}
+
 func parseCommand(ctx types.Context, prf *types.ParsedRunfile, taskEnv map[string]string, command any) (*types.ParsedCommandJson, error) {
 	ferr := func(err error) error {
 		return errors.ErrTaskInvalidCommand.Wrap(err).KV("command", command)
@@ -38,15 +46,20 @@ func parseCommand(ctx types.Context, prf *types.ParsedRunfile, taskEnv map[strin
 			}
 
 			pcj := types.ParsedCommandJson{
-				Env: parsedEnv,
+				Env:         parsedEnv,
+				IgnoreError: cj.IgnoreError,
+				Echo:        cj.Echo,
+				Stdin:       cj.Stdin,
+			}
+
+			if cj.Silent {
+				pcj.Echo = types.EchoNone
 			}
 
 			switch {
 			case cj.Run != nil:
 				{
-					if ctx.TaskNamespace != "" {
-						*cj.Run = ctx.TaskNamespace + ":" + *cj.Run
-					}
+					*cj.Run = ResolveRunTarget(ctx.TaskNamespace, *cj.Run)
 					pcj.Run = cj.Run
 
 					if _, ok := prf.Tasks[*cj.Run]; !ok {
@@ -58,9 +71,14 @@ func parseCommand(ctx types.Context, prf *types.ParsedRunfile, taskEnv map[strin
 				{
 					pcj.Command = cj.Command
 				}
+			case cj.Defer != nil:
+				{
+					pcj.Command = cj.Defer
+					pcj.Defer = true
+				}
 			default:
 				{
-					return nil, fmt.Errorf("either 'run' or 'cmd' key, must be specified when setting command in json format")
+					return nil, ferr(fmt.Errorf("one of 'run', 'cmd' or 'defer' key, must be specified when setting command in json format"))
 				}
 			}
 
@@ -28,6 +28,26 @@ func testParseCommandJsonEqual(t *testing.T, got, want *types.ParsedCommandJson)
 		t.Errorf("parseCommand(),\n[.env] \n\tgot = %+v\n\twant = %+v", got.Env, want.Env)
 		return
 	}
+
+	if got.IgnoreError != want.IgnoreError {
+		t.Errorf("parseCommand(),\n[.ignoreError] \n\tgot = %v\n\twant = %v", got.IgnoreError, want.IgnoreError)
+		return
+	}
+
+	if got.Defer != want.Defer {
+		t.Errorf("parseCommand(),\n[.defer] \n\tgot = %v\n\twant = %v", got.Defer, want.Defer)
+		return
+	}
+
+	if got.Echo != want.Echo {
+		t.Errorf("parseCommand(),\n[.echo] \n\tgot = %v\n\twant = %v", got.Echo, want.Echo)
+		return
+	}
+
+	if !reflect.DeepEqual(got.Stdin, want.Stdin) {
+		t.Errorf("parseCommand(),\n[.stdin] \n\tgot = %+v\n\twant = %+v", got.Stdin, want.Stdin)
+		return
+	}
 }
 
 func Test_parseCommand(t *testing.T) {
@@ -99,6 +119,120 @@ func Test_parseCommand(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "4. must pass with command, and ignoreError set",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":         "golangci-lint run",
+					"ignoreError": true,
+				},
+			},
+			want: &types.ParsedCommandJson{
+				Command:     fn.New("golangci-lint run"),
+				Env:         map[string]string{},
+				IgnoreError: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "5. must pass with command, and echo set",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":  "go build",
+					"echo": "line",
+				},
+			},
+			want: &types.ParsedCommandJson{
+				Command: fn.New("go build"),
+				Env:     map[string]string{},
+				Echo:    types.EchoLine,
+			},
+			wantErr: false,
+		},
+		{
+			name: "6. must pass with command, and silent set, resolving to echo none",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":    "go build",
+					"silent": true,
+				},
+			},
+			want: &types.ParsedCommandJson{
+				Command: fn.New("go build"),
+				Env:     map[string]string{},
+				Echo:    types.EchoNone,
+			},
+			wantErr: false,
+		},
+		{
+			name: "7. must pass with stdin from file",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":   "cat",
+					"stdin": "file:data.json",
+				},
+			},
+			want: &types.ParsedCommandJson{
+				Command: fn.New("cat"),
+				Env:     map[string]string{},
+				Stdin:   &types.Stdin{Mode: types.StdinFile, Value: "data.json"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "8. must pass with stdin set to true, resolving to inherit",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":   "cat",
+					"stdin": true,
+				},
+			},
+			want: &types.ParsedCommandJson{
+				Command: fn.New("cat"),
+				Env:     map[string]string{},
+				Stdin:   &types.Stdin{Mode: types.StdinInherit},
+			},
+			wantErr: false,
+		},
+		{
+			name: "9. must pass with literal stdin",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":   "cat",
+					"stdin": "hello\n",
+				},
+			},
+			want: &types.ParsedCommandJson{
+				Command: fn.New("cat"),
+				Env:     map[string]string{},
+				Stdin:   &types.Stdin{Mode: types.StdinLiteral, Value: "hello\n"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "10. must fail with invalid echo",
+			args: args{
+				prf:     &types.ParsedRunfile{},
+				taskEnv: map[string]string{},
+				command: map[string]any{
+					"cmd":  "go build",
+					"echo": "loud",
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for i := range tests {
@@ -17,6 +17,9 @@ type evaluationParams struct {
 	Env map[string]string
 }
 
+// UnevaluatedShFormat is how value of an env var with `sh` key looks, when it is not evaluated (in dry run)
+const UnevaluatedShFormat = "<sh: %s>"
+
 /*
 EnvVar can be provided in multiple forms:
 
@@ -102,6 +105,11 @@ func parseEnvVars(ctx types.Context, ev types.EnvVar, params evaluationParams) (
 			case specials.Sh != nil:
 				{
 					*specials.Sh = strings.TrimSpace(*specials.Sh)
+					if ctx.DryRun && !ctx.EvalEnv {
+						env[k] = fmt.Sprintf(UnevaluatedShFormat, *specials.Sh)
+						continue
+					}
+
 					cmd := exec.CommandContext(ctx, "sh", "-c", *specials.Sh)
 					cmd.Env = fn.ToEnviron(params.Env)
 
@@ -13,6 +13,7 @@ func Test_ParseEnvVars(t *testing.T) {
 	type args struct {
 		envVars    EnvVar
 		testingEnv map[string]string
+		dryRun     bool
 	}
 
 	type test struct {
@@ -108,11 +109,26 @@ func Test_ParseEnvVars(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "7. must pass [when] in dry run, sh command is not evaluated",
+			args: args{
+				envVars: EnvVar{
+					"hello": map[string]any{
+						"sh": "exit 1",
+					},
+				},
+				dryRun: true,
+			},
+			want: map[string]string{
+				"hello": "<sh: exit 1>",
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseEnvVars(Context{Context: context.TODO(), Logger: log.New(), TaskName: "test"}, tt.args.envVars, evaluationParams{
+			got, err := parseEnvVars(Context{Context: context.TODO(), Logger: log.New(), TaskName: "test", DryRun: tt.args.dryRun}, tt.args.envVars, evaluationParams{
 				Env: tt.args.testingEnv,
 			})
 			if (err != nil) != tt.wantErr {
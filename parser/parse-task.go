@@ -5,7 +5,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/nxtcoder17/runfile/errors"
 	fn "github.com/nxtcoder17/runfile/functions"
 	"github.com/nxtcoder17/runfile/types"
@@ -70,6 +72,10 @@ func ParseTask(ctx types.Context, prf *types.ParsedRunfile, task types.Task) (*t
 		}
 
 		if requirement.Sh != nil {
+			if ctx.DryRun {
+				continue
+			}
+
 			cmd := exec.CommandContext(taskCtx, "sh", "-c", *requirement.Sh)
 			cmd.Env = fn.ToEnviron(taskEnv)
 			cmd.Stdout = fn.Must(os.OpenFile(os.DevNull, os.O_WRONLY, 0o755))
@@ -108,6 +114,15 @@ func ParseTask(ctx types.Context, prf *types.ParsedRunfile, task types.Task) (*t
 		commands = append(commands, *c2)
 	}
 
+	finally := make([]types.ParsedCommandJson, 0, len(task.Finally))
+	for i := range task.Finally {
+		c2, err := parseCommand(ctx, prf, taskEnv, task.Finally[i])
+		if err != nil {
+			return nil, err
+		}
+		finally = append(finally, *c2)
+	}
+
 	watch := task.Watch
 	if watch != nil {
 		for i := range watch.Dirs {
@@ -115,6 +130,37 @@ func ParseTask(ctx types.Context, prf *types.ParsedRunfile, task types.Task) (*t
 				watch.Dirs[i] = filepath.Join(*task.Dir, watch.Dirs[i])
 			}
 		}
+
+		patterns := append(append([]string{}, watch.Include...), watch.Exclude...)
+		for pattern, target := range watch.On {
+			if _, ok := prf.Tasks[target]; !ok {
+				return nil, errors.ErrTaskNotFound.Wrap(fmt.Errorf("invalid watch route")).KV("task", task.Name, "route", pattern, "run-target", target)
+			}
+			patterns = append(patterns, pattern)
+		}
+
+		for _, pattern := range patterns {
+			if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+				return nil, errors.ErrTaskParsingFailed.Wrap(fmt.Errorf("invalid watch glob (%s)", pattern)).KV("task", task.Name)
+			}
+		}
+	}
+
+	var taskLog *types.TaskLog
+	if task.Log != nil {
+		if task.Log.File == "" {
+			return nil, errors.ErrTaskParsingFailed.Wrap(fmt.Errorf("log.file must be set")).KV("task", task.Name)
+		}
+
+		if task.Log.Rotate < 0 {
+			return nil, errors.ErrTaskParsingFailed.Wrap(fmt.Errorf("log.rotate must not be negative")).KV("task", task.Name, "rotate", task.Log.Rotate)
+		}
+
+		file := strings.ReplaceAll(task.Log.File, "{{task}}", task.Name)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(*task.Dir, file)
+		}
+		taskLog = &types.TaskLog{File: file, Rotate: task.Log.Rotate}
 	}
 
 	return &types.ParsedTask{
@@ -123,9 +169,23 @@ func ParseTask(ctx types.Context, prf *types.ParsedRunfile, task types.Task) (*t
 		Shell:       task.Shell,
 		WorkingDir:  *task.Dir,
 		Interactive: task.Interactive,
+		TTY:         task.TTY,
+		Stdin:       task.Stdin,
+		Log:         taskLog,
+		Output:      task.Output,
+		Echo:        task.Echo,
+		Color:       task.Color,
+		IgnoreError: task.IgnoreError,
+		PassArgs:    task.PassArgs,
+		Template:    task.Template,
 		Env:         taskEnv,
 		Commands:    commands,
+		Finally:     finally,
 		Watch:       watch,
 		Parallel:    task.Parallel,
+		FailFast:    fn.DefaultIfNil(task.FailFast, true),
+		MaxParallel: task.MaxParallel,
+		Weight:      task.Weight,
+		Priority:    task.Priority,
 	}, nil
 }
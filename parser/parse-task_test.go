@@ -53,8 +53,16 @@ func Test_ParseTask(t *testing.T) {
 
 		for i := 0; i < len(got.Commands); i++ {
 			testParseCommandJsonEqual(t, &got.Commands[i], &want.Commands[i])
+		}
+
+		if len(got.Finally) != len(want.Finally) {
+			t.Errorf("ParseTask(),\n[len(.Finally) not equal]\n\tgot = %+v\n\twant = %+v", len(got.Finally), len(want.Finally))
 			return
 		}
+
+		for i := 0; i < len(got.Finally); i++ {
+			testParseCommandJsonEqual(t, &got.Finally[i], &want.Finally[i])
+		}
 	}
 
 	// for dotenv test
@@ -595,6 +603,41 @@ echo "hi"
 			},
 			wantErr: false,
 		},
+
+		{
+			name: "19. [commands] defer and finally commands",
+			args: args{
+				ctx: nil,
+				rf: &ParsedRunfile{
+					Tasks: map[string]Task{
+						"test": {
+							Commands: []any{
+								"mkdir -p /tmp/runfile-test",
+								map[string]any{
+									"defer": "rm -rf /tmp/runfile-test",
+								},
+							},
+							Finally: []any{
+								"echo $RUNFILE_TASK_STATUS",
+							},
+						},
+					},
+				},
+				taskName: "test",
+			},
+			want: &ParsedTask{
+				Shell:      []string{"sh", "-c"},
+				WorkingDir: fn.Must(os.Getwd()),
+				Commands: []ParsedCommandJson{
+					{Command: fn.New("mkdir -p /tmp/runfile-test")},
+					{Command: fn.New("rm -rf /tmp/runfile-test"), Defer: true},
+				},
+				Finally: []ParsedCommandJson{
+					{Command: fn.New("echo $RUNFILE_TASK_STATUS")},
+				},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -17,11 +17,19 @@ func parseRunfile(ctx types.Context, runfile *types.Runfile) (*types.ParsedRunfi
 		Tasks: make(map[string]types.Task),
 	}
 	prf.Metadata.RunfilePath = runfile.Filepath
+	prf.Metadata.Files = []string{runfile.Filepath}
 
 	for k, task := range runfile.Tasks {
 		task.Name = k
 		task.Metadata.RunfilePath = &prf.Metadata.RunfilePath
 		prf.Tasks[k] = task
+
+		for _, de := range task.DotEnv {
+			if !filepath.IsAbs(de) {
+				de = filepath.Join(filepath.Dir(runfile.Filepath), de)
+			}
+			prf.Metadata.Files = append(prf.Metadata.Files, de)
+		}
 	}
 
 	includes, err := parseIncludes(ctx, runfile.Includes)
@@ -40,6 +48,8 @@ func parseRunfile(ctx types.Context, runfile *types.Runfile) (*types.ParsedRunfi
 		for k, v := range included.Env {
 			prf.Env[k] = v
 		}
+
+		prf.Metadata.Files = append(prf.Metadata.Files, included.Metadata.Files...)
 	}
 
 	dotEnvFiles := make([]string, 0, len(runfile.DotEnv))
@@ -52,6 +62,7 @@ func parseRunfile(ctx types.Context, runfile *types.Runfile) (*types.ParsedRunfi
 		}
 		dotEnvFiles = append(dotEnvFiles, de)
 	}
+	prf.Metadata.Files = append(prf.Metadata.Files, dotEnvFiles...)
 
 	// dotenvVars, err := parseDotEnvFiles(runfile.DotEnv...)
 	dotenvVars, err := parseDotEnvFiles(dotEnvFiles...)
@@ -3,6 +3,9 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/nxtcoder17/runfile/types"
@@ -60,3 +63,30 @@ func Test_parseRunfile(t *testing.T) {
 		})
 	}
 }
+
+func Test_ParseRunfileFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	write(".env", "A=1\n")
+	write("task.env", "B=2\n")
+	write("lib.env", "C=3\n")
+	lib := write("Lib.yml", "dotEnv: [lib.env]\ntasks:\n  echo:\n    cmd: [echo]\n")
+	runfile := write("Runfile.yml", fmt.Sprintf("dotEnv: [.env]\nincludes:\n  lib:\n    runfile: %s\ntasks:\n  build:\n    dotEnv: [task.env]\n    cmd: [echo]\n", lib))
+
+	prf, err := ParseRunfile(types.Context{Context: t.Context()}, runfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{runfile, filepath.Join(dir, "task.env"), lib, filepath.Join(dir, "lib.env"), filepath.Join(dir, ".env")}
+	if !reflect.DeepEqual(prf.Metadata.Files, want) {
+		t.Errorf("ParseRunfile(), files\n\tgot = %v\n\twant = %v", prf.Metadata.Files, want)
+	}
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	fn "github.com/nxtcoder17/runfile/functions"
+)
+
+// ArgsEnvVar holds forwarded CLI args, shell quoted, for tasks with `passArgs: true`
+const ArgsEnvVar = "RUNFILE_ARGS"
+
+// ChangedFilesEnvVar holds files that changed, newline separated, for runs triggered by watch mode
+const ChangedFilesEnvVar = "RUNFILE_CHANGED_FILES"
+
+var posixShells = []string{"sh", "bash", "zsh", "dash", "ksh", "ash"}
+
+// isPosixShell checks if shell treats first arg after the script as $0
+func isPosixShell(shell string) bool {
+	return slices.Contains(posixShells, filepath.Base(shell))
+}
+
+// renderCommand renders command as a go template, with forwarded args as `{{ .Args }}` (shell quoted),
+// and `{{ .ArgList }}` (as a list), and files changed in watch mode as `{{ .ChangedFiles }}` (shell quoted),
+// and `{{ .ChangedFileList }}` (as a list)
+func renderCommand(cmd string, args []string, changedFiles []string) (string, error) {
+	if !strings.Contains(cmd, "{{") {
+		return cmd, nil
+	}
+
+	t, err := template.New("command").Option("missingkey=error").Parse(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	b := new(bytes.Buffer)
+	if err := t.Execute(b, map[string]any{
+		"Args":    fn.ShellQuote(args...),
+		"ArgList": args,
+
+		"ChangedFiles":    fn.ShellQuote(changedFiles...),
+		"ChangedFileList": changedFiles,
+	}); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_CreateCommandWithArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell []string
+		args  []string
+		want  []string
+	}{
+		{
+			name:  "1. posix shell, gets its name as $0, followed by args",
+			shell: []string{"sh", "-c"},
+			args:  []string{"-run", "TestFoo"},
+			want:  []string{"sh", "-c", "echo", "sh", "-run", "TestFoo"},
+		},
+		{
+			name:  "2. other interpreters, get args appended as is",
+			shell: []string{"python", "-c"},
+			args:  []string{"-v"},
+			want:  []string{"python", "-c", "echo", "-v"},
+		},
+		{
+			name:  "3. no args, nothing is appended",
+			shell: []string{"bash", "-c"},
+			want:  []string{"bash", "-c", "echo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CreateCommand(context.TODO(), CmdArgs{Shell: tt.shell, Cmd: "echo", Args: tt.args})
+			if strings.Join(c.Args, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("CreateCommand(), args\n\tgot = %q\n\twant = %q", c.Args, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		cmd          string
+		args         []string
+		changedFiles []string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name: "1. command without template, is left as is",
+			cmd:  "echo hello",
+			args: []string{"a"},
+			want: "echo hello",
+		},
+		{
+			name: "2. .Args renders shell quoted args",
+			cmd:  "go test {{ .Args }}",
+			args: []string{"-run", "Test Foo"},
+			want: "go test -run 'Test Foo'",
+		},
+		{
+			name: "3. .ArgList can be indexed",
+			cmd:  "echo {{ index .ArgList 1 }}",
+			args: []string{"a", "b"},
+			want: "echo b",
+		},
+		{
+			name:         "4. .ChangedFiles renders shell quoted files",
+			cmd:          "go test {{ .ChangedFiles }}",
+			changedFiles: []string{"pkg/a.go", "my file.go"},
+			want:         "go test pkg/a.go 'my file.go'",
+		},
+		{
+			name:         "5. .ChangedFileList can be ranged over",
+			cmd:          "{{ range .ChangedFileList }}lint {{ . }};{{ end }}",
+			changedFiles: []string{"a.go", "b.go"},
+			want:         "lint a.go;lint b.go;",
+		},
+		{
+			name:    "6. [unhappy] invalid template",
+			cmd:     "echo {{ .Args ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderCommand(tt.cmd, tt.args, tt.changedFiles)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("renderCommand(), error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("renderCommand()\n\tgot = %q\n\twant = %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	fn "github.com/nxtcoder17/runfile/functions"
+)
+
+// planNode is a node of execution plan tree, printed in dry run mode
+type planNode struct {
+	title    string
+	details  []string
+	children []planNode
+}
+
+func executionMode(parallel bool) string {
+	if parallel {
+		return "parallel"
+	}
+	return "sequential"
+}
+
+// envDiff lists env vars of a command, that are either not present, or different in OS env
+func envDiff(environ []string) []string {
+	osEnv := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			osEnv[k] = v
+		}
+	}
+
+	cmdEnv := make(map[string]string)
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			cmdEnv[k] = v
+		}
+	}
+
+	keys := make([]string, 0, len(cmdEnv))
+	for k := range cmdEnv {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	diff := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v, ok := osEnv[k]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("+ %s=%s", k, cmdEnv[k]))
+		case v != cmdEnv[k]:
+			diff = append(diff, fmt.Sprintf("~ %s=%s", k, cmdEnv[k]))
+		}
+	}
+	return diff
+}
+
+// buildPlan creates command of every group, without starting them, to describe what would be executed
+func buildPlan(ctx context.Context, cg CommandGroup) planNode {
+	isTask := len(cg.Groups) > 0 || len(cg.Finally) > 0
+
+	node := planNode{}
+	if isTask {
+		node.title = fmt.Sprintf("%s [%s]", cg.TaskName, executionMode(cg.Parallel))
+	}
+
+	var labels []string
+	if cg.Deferred {
+		labels = append(labels, "defer")
+	}
+	if cg.IgnoreError {
+		labels = append(labels, "ignoreError")
+	}
+	if len(labels) > 0 {
+		node.title = strings.TrimSpace(node.title + " (" + strings.Join(labels, ", ") + ")")
+	}
+
+	for i := range cg.Groups {
+		node.children = append(node.children, buildPlan(ctx, cg.Groups[i]))
+	}
+
+	for _, cmd := range cg.Commands {
+		c := cmd.Create(ctx)
+
+		cmdNode := planNode{title: node.title}
+		if isTask {
+			cmdNode.title = ""
+		}
+		for i, line := range strings.Split(strings.TrimSpace(cmd.Text), "\n") {
+			prompt := "  "
+			if i == 0 {
+				prompt = "$ "
+			}
+			cmdNode.details = append(cmdNode.details, prompt+line)
+		}
+		cmdNode.details = append(cmdNode.details, "shell: "+strings.Join(cmd.Shell, " "))
+		if len(cmd.Args) > 0 {
+			cmdNode.details = append(cmdNode.details, "args:  "+fn.ShellQuote(cmd.Args...))
+		}
+		cmdNode.details = append(cmdNode.details, "dir:   "+c.Dir)
+
+		for i, kv := range envDiff(c.Env) {
+			label := "       "
+			if i == 0 {
+				label = "env:   "
+			}
+			cmdNode.details = append(cmdNode.details, label+kv)
+		}
+
+		if !isTask && len(cg.Commands) == 1 {
+			// INFO: a command group, with a single command is shown as the command itself
+			return cmdNode
+		}
+		node.children = append(node.children, cmdNode)
+	}
+
+	if len(cg.Finally) > 0 {
+		finally := planNode{title: "finally [sequential]"}
+		for i := range cg.Finally {
+			finally.children = append(finally.children, buildPlan(ctx, cg.Finally[i]))
+		}
+		node.children = append(node.children, finally)
+	}
+
+	return node
+}
+
+func printPlan(w io.Writer, node planNode, indent string, isLast bool, isRoot bool) {
+	branch, childIndent := "├─ ", indent+"│  "
+	if isLast {
+		branch, childIndent = "└─ ", indent+"   "
+	}
+	if isRoot {
+		branch, childIndent = "", ""
+	}
+
+	lines := node.details
+	if node.title != "" {
+		lines = append([]string{"● " + node.title}, lines...)
+	}
+
+	for i, line := range lines {
+		if i == 0 {
+			fmt.Fprintf(w, "%s%s%s\n", indent, branch, line)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s\n", childIndent, line)
+	}
+
+	for i := range node.children {
+		printPlan(w, node.children[i], childIndent, i == len(node.children)-1, false)
+	}
+}
@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventTaskStarted     EventKind = "task.started"
+	EventTaskFinished    EventKind = "task.finished"
+	EventCommandStarted  EventKind = "command.started"
+	EventCommandFinished EventKind = "command.finished"
+)
+
+// Event is emitted by executor, as tasks and their commands start and finish.
+// Summary, and reports of a run are all built from these events
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Time time.Time `json:"time"`
+
+	// TaskID identifies a single execution of a task, as the same task may run more than once in a run
+	TaskID int `json:"taskId"`
+
+	// ParentTaskID is the task, that ran this one as a `run` target. It is 0 for tasks, run directly
+	ParentTaskID int    `json:"parentTaskId,omitempty"`
+	TaskName     string `json:"task"`
+
+	CommandID int    `json:"commandId,omitempty"`
+	Command   string `json:"command,omitempty"`
+
+	// Status, ExitCode and Ignored are only set for finished events
+	Status   string `json:"status,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	Ignored  bool   `json:"ignored,omitempty"`
+
+	// Stderr is tail of stderr of a failed command, it is only captured when a report is requested
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// maxCapturedStderr is the number of bytes, from the end of stderr, captured for every command
+const maxCapturedStderr = 64 * 1024
+
+// runReport records events across all the tasks of a run
+type runReport struct {
+	mu     sync.Mutex
+	seq    int
+	events []Event
+
+	// captureStderr, when true, records stderr of failed commands in their events
+	captureStderr bool
+
+	// watched is true, once any task of the run is watched. Such runs end only when interrupted, so they get no summary.
+	// Then, only the latest run of every top level task is kept, so that events do not pile up across reruns
+	watched bool
+
+	// roots maps ids of tasks to the id of the top level task, they run under. It is kept only when watched
+	roots map[int]int
+}
+
+// nextID returns a new id, for either a task or a command
+func (r *runReport) nextID() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	return r.seq
+}
+
+func (r *runReport) setWatched() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watched = true
+}
+
+func (r *runReport) isWatched() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.watched
+}
+
+func (r *runReport) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.watched && ev.Kind == EventTaskStarted {
+		r.trackRun(ev)
+	}
+	r.events = append(r.events, ev)
+}
+
+// trackRun records the top level task, that task of ev runs under. When a top level task starts again,
+// events of its previous runs are dropped. r.mu must be held
+func (r *runReport) trackRun(ev Event) {
+	if r.roots == nil {
+		r.roots = make(map[int]int)
+	}
+
+	if ev.ParentTaskID != 0 {
+		r.roots[ev.TaskID] = r.roots[ev.ParentTaskID]
+		return
+	}
+	r.roots[ev.TaskID] = ev.TaskID
+
+	stale := make(map[int]bool)
+	for _, e := range r.events {
+		if e.Kind == EventTaskStarted && e.ParentTaskID == 0 && e.TaskName == ev.TaskName {
+			stale[e.TaskID] = true
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	r.events = slices.DeleteFunc(r.events, func(e Event) bool { return stale[r.roots[e.TaskID]] })
+	for id, root := range r.roots {
+		if stale[root] {
+			delete(r.roots, id)
+		}
+	}
+}
+
+func (r *runReport) list() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event{}, r.events...)
+}
+
+// tailBuffer keeps only the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.buf = append(tb.buf, p...)
+	if len(tb.buf) > tb.max {
+		tb.buf = append(tb.buf[:0], tb.buf[len(tb.buf)-tb.max:]...)
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return string(tb.buf)
+}
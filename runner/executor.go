@@ -0,0 +1,577 @@
+package runner
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+	"github.com/nxtcoder17/runfile/errors"
+	"github.com/nxtcoder17/runfile/terminal"
+	"github.com/nxtcoder17/runfile/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// CommandGroup is a tree of commands, as resolved from a task and its `run` targets
+type CommandGroup struct {
+	// TaskName is the task, whose commands this group holds
+	TaskName string
+
+	Groups   []CommandGroup
+	Commands []Command
+
+	// PreExecCommand is called before every command under this group gets executed,
+	// with w being stderr of the task, command belongs to
+	PreExecCommand func(w io.Writer, cmd Command)
+
+	// Output decides how output of this task's commands is shown, it is inherited from parent, when empty
+	Output types.OutputMode
+
+	// Color of prefix, for output of this group's commands
+	Color types.Color
+
+	// Log, when set, tees output of this task's commands to a file
+	Log *types.TaskLog
+
+	// Parallel runs the groups, and commands of this group in parallel
+	Parallel bool
+
+	// FailFast, when true, cancels the remaining groups, or commands of a parallel group, as soon
+	// as one of them fails. When false, all of them are waited for
+	FailFast bool
+
+	// MaxParallel limits how many of the groups, or commands of this group run at once, 0 means no limit
+	MaxParallel int
+
+	// Weight is the number of scheduler slots, each command of this group takes
+	Weight int
+
+	// Priority decides which command gets scheduler slots first, when they are contended
+	Priority int
+
+	// IgnoreError, when true, records failures under this group, but does not
+	// fail the group
+	IgnoreError bool
+
+	// Finally groups always run after this group's groups, irrespective of
+	// whether they succeeded, failed or got cancelled
+	Finally []CommandGroup
+
+	// Deferred groups are not executed when reached, but are registered to run,
+	// in LIFO order, just before the enclosing task's Finally groups
+	Deferred bool
+}
+
+// Command is a single command of a group
+type Command struct {
+	// Shell is the interpreter, command runs in
+	Shell []string
+
+	// Text is the command, as written in runfile
+	Text string
+
+	// Args are forwarded CLI args, appended to the interpreter invocation
+	Args []string
+
+	// Echo is how command is shown, before it is executed
+	Echo types.EchoMode
+
+	// TTY runs command in a pseudo terminal, so that it keeps colors, and progress output
+	TTY bool
+
+	// Stdin of command, when nil, stdin of `run` is passed along, only if it is piped into,
+	// and command is not running alongside others
+	Stdin *types.Stdin
+
+	Create func(context.Context) *exec.Cmd
+}
+
+// ptyUnsupported warns only once, when pty could not be allocated
+var ptyUnsupported sync.Once
+
+// stdinIsTerminal warns only once, when a command should inherit stdin, but it is a terminal
+var stdinIsTerminal sync.Once
+
+const (
+	TaskStatusSuccess   = "success"
+	TaskStatusFailure   = "failure"
+	TaskStatusCancelled = "cancelled"
+
+	// TaskStatusRunning is only reported, for tasks that never finished, i.e. when run got force killed
+	TaskStatusRunning = "running"
+)
+
+type cmdExecutorArgs struct {
+	Logger   log.Logger
+	Commands []CommandGroup
+	Parallel bool
+
+	// Report records events of tasks, and their commands
+	Report *runReport
+
+	// Stdout, and Stderr are where output of all commands finally gets written, in OutputMode, unless a task sets its own
+	Stdout     io.Writer
+	Stderr     io.Writer
+	OutputMode types.OutputMode
+
+	// Timestamps are added to every line of output, unless empty
+	Timestamps types.TimestampMode
+
+	// Concurrent is true, when this executor runs alongside others, i.e. `run -p`
+	Concurrent bool
+
+	// ShutdownTimeout, when set, is the time commands get to exit gracefully once stopped, instead of DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
+
+	// PrefixWidth is the width, task prefixes are padded to, as this executor runs alongside others
+	PrefixWidth int
+
+	// Scheduler is shared by all executors of a run, to limit commands running at once
+	Scheduler *scheduler
+
+	// KeepGoing lets all groups, and commands of parallel groups finish, even when one of them fails,
+	// irrespective of their FailFast
+	KeepGoing bool
+}
+
+// cmdExecutor executes command groups, and can be stopped, and started again by watch mode
+type cmdExecutor struct {
+	ctx  context.Context
+	args cmdExecutorArgs
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newCmdExecutor(ctx context.Context, args cmdExecutorArgs) *cmdExecutor {
+	if args.Report == nil {
+		args.Report = &runReport{}
+	}
+	if args.Stdout == nil {
+		args.Stdout = &LogWriter{w: os.Stdout}
+	}
+	if args.Stderr == nil {
+		args.Stderr = &LogWriter{w: os.Stderr}
+	}
+	if args.OutputMode == "" {
+		args.OutputMode = types.OutputPrefixed
+	}
+	if args.Scheduler == nil {
+		// INFO: without a limit on the whole run, scheduler still enforces `maxParallel` of tasks
+		args.Scheduler = newScheduler(0)
+	}
+	return &cmdExecutor{ctx: ctx, args: args}
+}
+
+// Start executes all the command groups, and blocks until they finish
+func (e *cmdExecutor) Start() error {
+	ctx, cf := context.WithCancel(e.ctx)
+	done := make(chan struct{})
+
+	e.mu.Lock()
+	e.cancel = cf
+	e.done = done
+	commands := e.args.Commands
+	e.mu.Unlock()
+
+	defer close(done)
+	defer cf()
+
+	// INFO: root output never buffers, it only carries the default mode for tasks
+	out := &taskOutput{mode: e.args.OutputMode, stdout: e.args.Stdout, stderr: e.args.Stderr, timestamps: e.args.Timestamps}
+	return e.execGroups(ctx, commands, e.args.Parallel, true, 0, execState{scope: &taskScope{}, out: out, prefixWidth: e.args.PrefixWidth, parallel: e.args.Concurrent})
+}
+
+// setCommands replaces command groups, that next Start executes
+func (e *cmdExecutor) setCommands(groups []CommandGroup) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.args.Commands = groups
+}
+
+// Stop cancels the current execution, and waits for it to exit
+func (e *cmdExecutor) Stop() error {
+	e.mu.Lock()
+	cf, done := e.cancel, e.done
+	e.mu.Unlock()
+
+	if cf == nil {
+		return nil
+	}
+
+	cf()
+	<-done
+	return nil
+}
+
+// taskScope holds deferred groups, registered while executing a task
+type taskScope struct {
+	mu       sync.Mutex
+	deferred []CommandGroup
+}
+
+func (ts *taskScope) push(cg CommandGroup) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.deferred = append(ts.deferred, cg)
+}
+
+// execState is what a group inherits from its ancestors
+type execState struct {
+	preExec []func(io.Writer, Command)
+	ignored bool
+	scope   *taskScope
+
+	// out is output of the task being executed
+	out *taskOutput
+
+	// prefixWidth is the width, task prefixes are padded to, so that output of parallel tasks is aligned
+	prefixWidth int
+
+	// taskID is the id of the task being executed, events of commands are reported under it
+	taskID int
+
+	// env is appended to environment of every command
+	env []string
+
+	// parallel is true, when commands run alongside others
+	parallel bool
+}
+
+func (e *cmdExecutor) execGroups(ctx context.Context, groups []CommandGroup, parallel bool, failFast bool, maxParallel int, st execState) error {
+	if !parallel {
+		for i := range groups {
+			if err := e.execGroup(ctx, groups[i], st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	st.parallel = true
+	for i := range groups {
+		st.prefixWidth = max(st.prefixWidth, len(groups[i].TaskName))
+	}
+
+	pool := e.args.Scheduler.newPool(maxParallel)
+	g, gctx := newGroup(ctx, failFast && !e.args.KeepGoing)
+	for i := range groups {
+		g.Go(func() error {
+			if err := e.args.Scheduler.acquirePool(gctx, pool, groups[i].Priority); err != nil {
+				return err
+			}
+			defer e.args.Scheduler.releasePool(pool)
+			return e.execGroup(gctx, groups[i], st)
+		})
+	}
+
+	return g.Wait()
+}
+
+func (e *cmdExecutor) execGroup(ctx context.Context, cg CommandGroup, st execState) error {
+	if cg.Deferred {
+		cg.Deferred = false
+		st.scope.push(cg)
+		return nil
+	}
+
+	// INFO: a group with nested groups is a task, and deferred commands are scoped to it
+	isTask := len(cg.Groups) > 0 || len(cg.Finally) > 0
+
+	switch {
+	case cg.PreExecCommand == nil:
+	case isTask:
+		// INFO: preview of a `run` target replaces ones of the tasks, that run it, so that nested tasks
+		// do not preview their commands once per level, but only at the level, that runs them
+		st.preExec = []func(io.Writer, Command){cg.PreExecCommand}
+	default:
+		st.preExec = append(append([]func(io.Writer, Command){}, st.preExec...), cg.PreExecCommand)
+	}
+	st.ignored = st.ignored || cg.IgnoreError
+
+	var err error
+	if isTask {
+		st.scope = &taskScope{}
+
+		mode := cg.Output
+		if mode == "" {
+			mode = st.out.mode
+		}
+		st.out = st.out.child(mode)
+
+		parentID := st.taskID
+		st.taskID = e.args.Report.nextID()
+		e.args.Report.emit(Event{Kind: EventTaskStarted, TaskID: st.taskID, ParentTaskID: parentID, TaskName: cg.TaskName})
+
+		if cg.Log != nil {
+			var f *os.File
+			if f, err = openTaskLog(*cg.Log); err == nil {
+				defer f.Close()
+				st.out.log = &LogWriter{w: f}
+			}
+		}
+	}
+
+	if err == nil {
+		err = e.execGroups(ctx, cg.Groups, cg.Parallel, cg.FailFast, cg.MaxParallel, st)
+	}
+	if err == nil {
+		err = e.execCommands(ctx, cg, st)
+	}
+
+	if isTask {
+		err = e.execFinally(ctx, cg, st, err)
+		status, exitCode := taskStatusOf(ctx, err)
+		if oerr := st.out.finish(status == TaskStatusFailure); oerr != nil {
+			e.args.Logger.Debug("failed to flush output", "task", cg.TaskName, "err", oerr)
+		}
+		e.args.Report.emit(Event{
+			Kind:     EventTaskFinished,
+			TaskID:   st.taskID,
+			TaskName: cg.TaskName,
+			Status:   status,
+			ExitCode: exitCode,
+			Ignored:  err != nil && st.ignored,
+		})
+	}
+
+	if err != nil && cg.IgnoreError {
+		e.args.Logger.Debug("ignoring error", "task", cg.TaskName, "err", err)
+		return nil
+	}
+
+	return err
+}
+
+// execFinally runs deferred, and finally groups of a task, with a context that
+// is not cancelled along with the task. It returns the task's original error, if any
+func (e *cmdExecutor) execFinally(ctx context.Context, cg CommandGroup, st execState, taskErr error) error {
+	st.scope.mu.Lock()
+	groups := make([]CommandGroup, 0, len(st.scope.deferred)+len(cg.Finally))
+	for i := len(st.scope.deferred) - 1; i >= 0; i-- {
+		groups = append(groups, st.scope.deferred[i])
+	}
+	st.scope.mu.Unlock()
+	groups = append(groups, cg.Finally...)
+
+	if len(groups) == 0 {
+		return taskErr
+	}
+
+	status, exitCode := taskStatusOf(ctx, taskErr)
+
+	st.env = append(append([]string{}, st.env...),
+		"RUNFILE_TASK_STATUS="+status,
+		fmt.Sprintf("RUNFILE_EXIT_CODE=%d", exitCode),
+	)
+
+	fctx := context.WithoutCancel(ctx)
+	for i := range groups {
+		if err := e.execGroup(fctx, groups[i], st); err != nil {
+			e.args.Logger.Debug("finally command failed", "task", cg.TaskName, "err", err)
+			if taskErr == nil {
+				taskErr = err
+			}
+		}
+	}
+
+	return taskErr
+}
+
+func (e *cmdExecutor) execCommands(ctx context.Context, cg CommandGroup, st execState) error {
+	run := func(ctx context.Context, cmd Command, pool *slotPool) error {
+		// INFO: only commands take scheduler slots, groups do not, so that nested groups can never deadlock
+		if err := e.args.Scheduler.acquire(ctx, pool, cg.Weight, cg.Priority); err != nil {
+			return err
+		}
+		defer e.args.Scheduler.release(pool, cg.Weight)
+
+		c := cmd.Create(ctx)
+		c.Env = append(c.Env, st.env...)
+
+		// INFO: interactive commands are attached to the terminal, and are left as is
+		interactive := c.Stdin != nil
+
+		var writers []io.Writer
+		if !interactive {
+			if e.args.ShutdownTimeout > 0 {
+				setProcessGroup(c, e.args.ShutdownTimeout)
+			}
+
+			c.Stdout, c.Stderr = st.out.commandWriters(cg.TaskName, cg.Color, st.prefixWidth)
+			writers = append(writers, c.Stdout, c.Stderr)
+
+			stdin, closeStdin, err := e.commandStdin(cmd.Stdin, st.parallel)
+			if err != nil {
+				return errors.WithErr(err).KV("task", cg.TaskName, "command", cmd.Text)
+			}
+			defer closeStdin()
+			c.Stdin = stdin
+		}
+
+		for _, fn := range st.preExec {
+			fn(st.out.Stderr(), cmd)
+		}
+
+		var stderr *tailBuffer
+		if e.args.Report.captureStderr && !interactive {
+			stderr = newTailBuffer(maxCapturedStderr)
+			c.Stderr = io.MultiWriter(c.Stderr, stderr)
+		}
+
+		// INFO: with a pty, stdout, and stderr of command are the same, and whole of it is captured as stderr
+		var pty *ptySession
+		if cmd.TTY && !interactive {
+			out := writers[0]
+			if stderr != nil {
+				out = io.MultiWriter(out, stderr)
+			}
+
+			reservedCols := 0
+			if st.out.mode != types.OutputRaw {
+				reservedCols = max(st.prefixWidth, len(cg.TaskName)) + 3
+			}
+
+			var perr error
+			if pty, perr = startPTY(c, out, reservedCols); perr != nil {
+				ptyUnsupported.Do(func() {
+					e.args.Logger.Warn("failed to allocate pty, running with pipes", "task", cg.TaskName, "err", perr)
+				})
+			} else {
+				setTTY(writers[0])
+			}
+		}
+
+		ev := Event{TaskID: st.taskID, TaskName: cg.TaskName, CommandID: e.args.Report.nextID(), Command: cmd.Text}
+		started := ev
+		started.Kind = EventCommandStarted
+		e.args.Report.emit(started)
+
+		err := runCommand(c)
+		if pty != nil {
+			pty.close()
+		}
+		for _, w := range writers {
+			if f, ok := w.(interface{ Flush() error }); ok {
+				if ferr := f.Flush(); ferr != nil {
+					e.args.Logger.Debug("failed to flush output", "task", cg.TaskName, "err", ferr)
+				}
+			}
+		}
+
+		ev.Kind = EventCommandFinished
+		switch {
+		case err == nil:
+			ev.Status, ev.ExitCode = TaskStatusSuccess, errors.ExitCodeSuccess
+		case ctx.Err() != nil:
+			ev.Status, ev.ExitCode = TaskStatusCancelled, errors.ExitCodeInterrupted
+		default:
+			ev.Status, ev.ExitCode, ev.Ignored = TaskStatusFailure, exitCodeOf(err), st.ignored
+			if stderr != nil {
+				ev.Stderr = stderr.String()
+			}
+		}
+		e.args.Report.emit(ev)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.ErrCommandFailed(ev.ExitCode).WithTaskName(cg.TaskName).Wrap(err).KV("task", cg.TaskName, "command", cmd.Text)
+		}
+		return nil
+	}
+
+	if !cg.Parallel {
+		for _, cmd := range cg.Commands {
+			if err := run(ctx, cmd, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	st.parallel = true
+	pool := e.args.Scheduler.newPool(cg.MaxParallel)
+	g, gctx := newGroup(ctx, cg.FailFast && !e.args.KeepGoing)
+	for _, cmd := range cg.Commands {
+		g.Go(func() error {
+			return run(gctx, cmd, pool)
+		})
+	}
+	return g.Wait()
+}
+
+// commandStdin opens what a command reads as its stdin. When s is nil, stdin of `run` is passed along,
+// only if it is piped into, and command is not running alongside others
+func (e *cmdExecutor) commandStdin(s *types.Stdin, parallel bool) (io.Reader, func() error, error) {
+	noop := func() error { return nil }
+
+	caps := terminal.Detect()
+	if s == nil {
+		if !caps.StdinPiped || parallel {
+			return nil, noop, nil
+		}
+		s = &types.Stdin{Mode: types.StdinInherit}
+	}
+
+	switch s.Mode {
+	case types.StdinInherit:
+		// INFO: commands run in their own process group, and reading from terminal would stop them with SIGTTIN
+		if caps.StdinTTY {
+			stdinIsTerminal.Do(func() {
+				e.args.Logger.Warn("stdin is a terminal, set `interactive: true` on the task, to read from it")
+			})
+			return nil, noop, nil
+		}
+		return os.Stdin, noop, nil
+	case types.StdinFile:
+		f, err := os.Open(s.Value)
+		if err != nil {
+			return nil, noop, errors.ErrStdinFile.Wrap(err).KV("file", s.Value)
+		}
+		return f, f.Close, nil
+	case types.StdinLiteral:
+		return strings.NewReader(s.Value), noop, nil
+	}
+
+	return nil, noop, nil
+}
+
+// newGroup creates an errgroup for running groups, or commands in parallel. With failFast, the returned
+// context gets cancelled as soon as one of them fails, otherwise it is ctx itself.
+// How many of them run at once is left to the scheduler
+func newGroup(ctx context.Context, failFast bool) (*errgroup.Group, context.Context) {
+	g, gctx := new(errgroup.Group), ctx
+	if failFast {
+		g, gctx = errgroup.WithContext(ctx)
+	}
+	return g, gctx
+}
+
+// taskStatusOf returns status, and exit code of a task, that finished with err
+func taskStatusOf(ctx context.Context, err error) (string, int) {
+	switch {
+	case ctx.Err() != nil || goerrors.Is(err, context.Canceled):
+		return TaskStatusCancelled, errors.ExitCodeInterrupted
+	case err != nil:
+		return TaskStatusFailure, errors.ExitCode(err)
+	default:
+		return TaskStatusSuccess, errors.ExitCodeSuccess
+	}
+}
+
+func exitCodeOf(err error) int {
+	var ee *exec.ExitError
+	if goerrors.As(err, &ee) {
+		return errors.CommandExitCode(ee)
+	}
+	return -1
+}
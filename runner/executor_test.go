@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"slices"
+	"testing"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+)
+
+func shellTask(name string, script string) CommandGroup {
+	return CommandGroup{
+		TaskName: name,
+		Groups: []CommandGroup{{
+			TaskName: name,
+			Commands: []Command{{
+				Shell: []string{"sh", "-c"},
+				Text:  script,
+				Create: func(ctx context.Context) *exec.Cmd {
+					c := exec.CommandContext(ctx, "sh", "-c", script)
+					setProcessGroup(c, DefaultShutdownTimeout)
+					return c
+				},
+			}},
+		}},
+	}
+}
+
+func Test_ParallelFailFast(t *testing.T) {
+	tests := []struct {
+		name     string
+		failFast bool
+		want     map[string]string
+	}{
+		{
+			name:     "1. fail fast, cancels siblings",
+			failFast: true,
+			want: map[string]string{
+				"parent": TaskStatusFailure,
+				"bad":    TaskStatusFailure,
+				"slow":   TaskStatusCancelled,
+			},
+		},
+		{
+			name:     "2. wait all, lets siblings finish",
+			failFast: false,
+			want: map[string]string{
+				"parent": TaskStatusFailure,
+				"bad":    TaskStatusFailure,
+				"slow":   TaskStatusSuccess,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &runReport{}
+			ex := newCmdExecutor(context.TODO(), cmdExecutorArgs{
+				Logger: log.New(),
+				Commands: []CommandGroup{{
+					TaskName: "parent",
+					Parallel: true,
+					FailFast: tt.failFast,
+					Groups: []CommandGroup{
+						shellTask("bad", "exit 3"),
+						shellTask("slow", "sleep 1"),
+					},
+				}},
+				Report: report,
+			})
+
+			if err := ex.Start(); err == nil {
+				t.Fatalf("Start(), expected error, got nil")
+			}
+
+			got := make(map[string]string)
+			walkTasks(taskTree(report.list()), 0, func(task *taskNode, depth int) {
+				got[task.Name] = task.Status
+				if task.Name == "bad" && task.ExitCode != 3 {
+					t.Errorf("exit code of task (bad) = %d, want 3", task.ExitCode)
+				}
+			})
+
+			for name, status := range tt.want {
+				if got[name] != status {
+					t.Errorf("status of task (%s) = %q, want %q", name, got[name], status)
+				}
+			}
+		})
+	}
+}
+
+func Test_PreExecCommand(t *testing.T) {
+	var got []string
+	preview := func(name string) func(io.Writer, Command) {
+		return func(w io.Writer, c Command) { got = append(got, name+": "+c.Text) }
+	}
+
+	// INFO: task `a` runs `b`, which runs `c`, whose command is previewed by `c`, and its own group
+	c := shellTask("c", "true")
+	c.PreExecCommand = preview("run c")
+	c.Groups[0].PreExecCommand = preview("box")
+
+	b := CommandGroup{TaskName: "b", PreExecCommand: preview("run b"), Groups: []CommandGroup{c}}
+
+	ex := newCmdExecutor(context.TODO(), cmdExecutorArgs{
+		Logger:   log.New(),
+		Commands: []CommandGroup{{TaskName: "a", Groups: []CommandGroup{b}}},
+		Stdout:   new(bytes.Buffer),
+		Stderr:   new(bytes.Buffer),
+	})
+
+	if err := ex.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"run c: true", "box: true"}; !slices.Equal(got, want) {
+		t.Errorf("previews of command, got = %q, want = %q", got, want)
+	}
+}
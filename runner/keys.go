@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// watchAction is sent to watch loops, as keys are pressed
+type watchAction int
+
+const (
+	watchActionRestart watchAction = iota + 1
+	watchActionPause
+	watchActionResume
+	watchActionQuit
+)
+
+const keysHelp = "keys: [r] restart, [c] clear screen, [p] pause/resume watching, [q] quit, [?] help"
+
+// keyboard reads single keys from stdin, when it is a terminal, and sends actions to watch loops, subscribed to it.
+// Clearing screen, and help are handled by keyboard itself, so that they happen once, for all watched tasks
+type keyboard struct {
+	in     *os.File
+	stdout io.Writer
+	stderr io.Writer
+
+	once    sync.Once
+	err     error
+	restore func()
+
+	mu     sync.Mutex
+	subs   []chan watchAction
+	paused bool
+}
+
+func newKeyboard(in *os.File, stdout io.Writer, stderr io.Writer) *keyboard {
+	return &keyboard{in: in, stdout: stdout, stderr: stderr}
+}
+
+// subscribe starts reading keys, on first call, and returns actions for a watch loop.
+// It returns nil, when keys can not be read
+func (k *keyboard) subscribe() <-chan watchAction {
+	k.once.Do(k.start)
+	if k.err != nil {
+		return nil
+	}
+
+	ch := make(chan watchAction, 4)
+	k.mu.Lock()
+	k.subs = append(k.subs, ch)
+	k.mu.Unlock()
+	return ch
+}
+
+func (k *keyboard) start() {
+	// INFO: in cbreak mode, keys are read as they are pressed, without being echoed, while Ctrl-C still interrupts
+	restore, err := enableCbreak(int(k.in.Fd()))
+	if err != nil {
+		k.err = err
+		return
+	}
+	k.restore = restore
+	k.print("press [?] for keys")
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := k.in.Read(buf); err != nil {
+				return
+			}
+			k.handle(buf[0])
+		}
+	}()
+}
+
+// close restores the terminal, if keys were being read
+func (k *keyboard) close() {
+	if k.restore != nil {
+		k.restore()
+	}
+}
+
+func (k *keyboard) print(msg string) {
+	pw := withDimmedPrefix(k.stderr, "run")
+	pw.Write([]byte(msg))
+	pw.Flush()
+}
+
+func (k *keyboard) handle(key byte) {
+	switch key {
+	case 'r':
+		k.print("restarting")
+		k.send(watchActionRestart)
+	case 'c':
+		fmt.Fprint(k.stdout, "\033[H\033[2J")
+	case 'p':
+		k.mu.Lock()
+		k.paused = !k.paused
+		paused := k.paused
+		k.mu.Unlock()
+
+		if paused {
+			k.print("paused watching, press [p] to resume")
+			k.send(watchActionPause)
+			return
+		}
+		k.print("resumed watching")
+		k.send(watchActionResume)
+	case 'q':
+		k.print("quitting")
+		k.send(watchActionQuit)
+	case '?':
+		k.print(keysHelp)
+	}
+}
+
+// send never blocks, as watch loops that have finished, no longer receive actions
+func (k *keyboard) send(action watchAction) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, ch := range k.subs {
+		select {
+		case ch <- action:
+		default:
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package runner
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// enableCbreak turns off line buffering, and echo of the terminal at fd, returning a func that restores it
+func enableCbreak(fd int) (func(), error) {
+	old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+
+	t := *old
+	t.Lflag &^= unix.ICANON | unix.ECHO
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &t); err != nil {
+		return nil, err
+	}
+
+	return func() { unix.IoctlSetTermios(fd, unix.TCSETS, old) }, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package runner
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// enableCbreak is only supported on linux, elsewhere watch mode runs without keys
+func enableCbreak(fd int) (func(), error) {
+	return nil, fmt.Errorf("reading keys is not supported on %s", runtime.GOOS)
+}
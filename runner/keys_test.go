@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_KeyboardHandle(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       string
+		want       []watchAction
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name: "1. r restarts, and q quits",
+			keys: "rq",
+			want: []watchAction{watchActionRestart, watchActionQuit},
+		},
+		{
+			name:       "2. p toggles between pause, and resume",
+			keys:       "pp",
+			want:       []watchAction{watchActionPause, watchActionResume},
+			wantStderr: "resumed watching",
+		},
+		{
+			name:       "3. c clears screen, without any action",
+			keys:       "c",
+			wantStdout: "\033[H\033[2J",
+		},
+		{
+			name:       "4. ? shows help, and other keys are ignored",
+			keys:       "x?",
+			wantStderr: keysHelp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+			k := newKeyboard(nil, stdout, stderr)
+			ch := make(chan watchAction, 4)
+			k.subs = append(k.subs, ch)
+
+			for _, key := range []byte(tt.keys) {
+				k.handle(key)
+			}
+			close(ch)
+
+			var got []watchAction
+			for a := range ch {
+				got = append(got, a)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions, got = %v, want = %v", got, tt.want)
+			}
+
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout, got = %q, want = %q", stdout.String(), tt.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr, got = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
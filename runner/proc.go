@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time, a command gets to exit gracefully after being
+// asked to stop, before it gets force killed
+const DefaultShutdownTimeout = 5 * time.Second
+
+// processGroups tracks process groups of all running commands, so that they all can be
+// force killed at once
+var processGroups = struct {
+	sync.Mutex
+	pids map[int]struct{}
+}{pids: make(map[int]struct{})}
+
+func trackProcessGroup(pid int) {
+	processGroups.Lock()
+	defer processGroups.Unlock()
+	processGroups.pids[pid] = struct{}{}
+}
+
+func untrackProcessGroup(pid int) {
+	processGroups.Lock()
+	defer processGroups.Unlock()
+	delete(processGroups.pids, pid)
+}
+
+func isProcessGroupTracked(pid int) bool {
+	processGroups.Lock()
+	defer processGroups.Unlock()
+	_, ok := processGroups.pids[pid]
+	return ok
+}
+
+// KillProcessGroups force kills every running command, along with all of its children
+func KillProcessGroups() {
+	processGroups.Lock()
+	defer processGroups.Unlock()
+	for pid := range processGroups.pids {
+		killProcessGroup(pid)
+	}
+}
+
+// runCommand starts the command, and waits for it to exit. Once the command exits,
+// whatever is left of its process group (e.g. background jobs) is killed as well
+func runCommand(c *exec.Cmd) error {
+	pipes, err := pipeOutput(c)
+	if err != nil {
+		closeOutput(pipes, 0)
+		return err
+	}
+
+	if err := c.Start(); err != nil {
+		closeOutput(pipes, 0)
+		return err
+	}
+
+	pid := c.Process.Pid
+	trackProcessGroup(pid)
+
+	err = c.Wait()
+
+	untrackProcessGroup(pid)
+	if c.SysProcAttr != nil {
+		killProcessGroup(pid)
+	}
+
+	// INFO: whatever escaped the process group (e.g. with setsid), may still hold onto output
+	closeOutput(pipes, c.WaitDelay)
+
+	// INFO: process exited successfully, but its children were holding onto its stdin
+	if errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+
+	return err
+}
+
+// outputPipe copies output of a command to its writer
+type outputPipe struct {
+	r, w   *os.File
+	copied chan struct{}
+}
+
+// pipeOutput sets up pipes for command's stdout, and stderr, when they are not files.
+// With exec's own pipes, Wait returns only after all output has been copied, and that
+// would keep a command's process group alive, as long as a background job holds its stdout.
+// With these, Wait returns as soon as the command exits, and output is copied till the
+// process group is gone
+func pipeOutput(c *exec.Cmd) ([]*outputPipe, error) {
+	var pipes []*outputPipe
+	pipe := func(w io.Writer) (*os.File, error) {
+		r, pw, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		p := &outputPipe{r: r, w: pw, copied: make(chan struct{})}
+		go func() {
+			defer close(p.copied)
+			io.Copy(w, r)
+		}()
+		pipes = append(pipes, p)
+		return pw, nil
+	}
+
+	if _, ok := c.Stdout.(*os.File); c.Stdout != nil && !ok {
+		shared := sameWriter(c.Stdout, c.Stderr)
+		f, err := pipe(c.Stdout)
+		if err != nil {
+			return pipes, err
+		}
+		c.Stdout = f
+		if shared {
+			c.Stderr = f
+		}
+	}
+
+	if _, ok := c.Stderr.(*os.File); c.Stderr != nil && !ok {
+		f, err := pipe(c.Stderr)
+		if err != nil {
+			return pipes, err
+		}
+		c.Stderr = f
+	}
+
+	return pipes, nil
+}
+
+// closeOutput waits for output to be copied, for upto timeout (0 means no limit), and closes the pipes
+func closeOutput(pipes []*outputPipe, timeout time.Duration) {
+	for _, p := range pipes {
+		// INFO: command has its own copy of write end, parent's copy must be closed, for copying to ever finish
+		p.w.Close()
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	for _, p := range pipes {
+		select {
+		case <-p.copied:
+		case <-expired:
+		}
+		p.r.Close()
+		<-p.copied
+	}
+}
+
+// sameWriter checks if a, and b are the same writer, writers that can not be compared are not
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
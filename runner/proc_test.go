@@ -0,0 +1,153 @@
+//go:build linux
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+	"github.com/nxtcoder17/runfile/types"
+)
+
+// isProcessAlive reports whether process is running, zombies are considered dead
+func isProcessAlive(pid int) bool {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+
+	// INFO: format is `pid (comm) state ...`, and comm may contain spaces
+	stat := string(b)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func readPid(t *testing.T, file string) int {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		b, err := os.ReadFile(file)
+		if err == nil && strings.HasSuffix(string(b), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("pid file %s was never written", file)
+	return 0
+}
+
+func waitForExit(t *testing.T, pid int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if !isProcessAlive(pid) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Errorf("grandchild process (pid: %d) is still alive", pid)
+}
+
+func Test_RunReapsGrandchildren(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		cancel  bool
+	}{
+		{
+			name:    "1. background job, left behind by a command that exits",
+			command: "sleep 300 > /dev/null 2>&1 & echo $! > %s",
+		},
+		{
+			name:    "2. grandchild, of a command that gets interrupted",
+			command: "sleep 300 & echo $! > %s; wait",
+			cancel:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pidFile := filepath.Join(dir, "pid")
+			runfilePath := filepath.Join(dir, "Runfile")
+
+			prf := &types.ParsedRunfile{
+				Tasks: map[string]types.Task{
+					"test": {
+						Name:     "test",
+						Dir:      &dir,
+						Commands: []any{fmt.Sprintf(tt.command, pidFile)},
+					},
+				},
+			}
+			prf.Metadata.RunfilePath = runfilePath
+
+			task := prf.Tasks["test"]
+			task.Metadata.RunfilePath = &runfilePath
+			prf.Tasks["test"] = task
+
+			ctx, cf := context.WithCancel(context.Background())
+			defer cf()
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- Run(types.NewContext(ctx, log.New()), prf, RunArgs{Tasks: []string{"test"}})
+			}()
+
+			pid := readPid(t, pidFile)
+			if tt.cancel {
+				cf()
+			}
+
+			select {
+			case err := <-errCh:
+				if !tt.cancel && err != nil {
+					t.Errorf("Run(), unexpected error = %v", err)
+				}
+			case <-time.After(2 * DefaultShutdownTimeout):
+				t.Fatalf("Run() did not exit")
+			}
+
+			waitForExit(t, pid)
+		})
+	}
+}
+
+func Test_RunCommandWithHeldOutput(t *testing.T) {
+	c := exec.CommandContext(t.Context(), "sh", "-c", "sleep 300 & echo $!; echo done")
+	setProcessGroup(c, DefaultShutdownTimeout)
+	out := new(bytes.Buffer)
+	c.Stdout, c.Stderr = out, out
+
+	startedAt := time.Now()
+	if err := runCommand(c); err != nil {
+		t.Fatalf("runCommand(), unexpected error = %v", err)
+	}
+
+	// INFO: background job holds onto stdout, but must not keep runCommand waiting for the shutdown timeout
+	if d := time.Since(startedAt); d > DefaultShutdownTimeout/2 {
+		t.Errorf("runCommand() took %s, must return as soon as the command exits", d)
+	}
+
+	lines := strings.Fields(out.String())
+	if len(lines) != 2 || lines[1] != "done" {
+		t.Fatalf("runCommand(), output, got = %q, want pid of background job, and done", out.String())
+	}
+
+	pid, err := strconv.Atoi(lines[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForExit(t, pid)
+}
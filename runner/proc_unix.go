@@ -0,0 +1,30 @@
+//go:build !windows
+
+package runner
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup runs command in its own process group, such that stopping it,
+// stops all of its children too.
+// On cancellation, the group gets SIGTERM, and if still alive after timeout, SIGKILL
+func setProcessGroup(c *exec.Cmd, timeout time.Duration) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error {
+		pid := c.Process.Pid
+		time.AfterFunc(timeout, func() {
+			if isProcessGroupTracked(pid) {
+				killProcessGroup(pid)
+			}
+		})
+		return syscall.Kill(-pid, syscall.SIGTERM)
+	}
+	c.WaitDelay = timeout
+}
+
+func killProcessGroup(pid int) {
+	_ = syscall.Kill(-pid, syscall.SIGKILL)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup on windows, only bounds the time spent waiting for command to exit
+func setProcessGroup(c *exec.Cmd, timeout time.Duration) {
+	c.WaitDelay = timeout
+}
+
+func killProcessGroup(pid int) {
+	if p, err := os.FindProcess(pid); err == nil {
+		_ = p.Kill()
+	}
+}
@@ -0,0 +1,143 @@
+//go:build linux
+
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// openPTY opens a new pseudo terminal, returning its master, and slave ends
+func openPTY() (*os.File, *os.File, error) {
+	// INFO: master is non blocking, so that it is read through go's poller, and closing it stops a pending read
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: "/dev/ptmx", Err: err}
+	}
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+// ptySession is a command, attached to a pseudo terminal, whose output is copied to a writer
+type ptySession struct {
+	master *os.File
+	slave  *os.File
+
+	sigs   chan os.Signal
+	stop   chan struct{}
+	copied chan struct{}
+
+	// waitDelay bounds the wait for output, after command exits
+	waitDelay time.Duration
+}
+
+// startPTY attaches command to a new pseudo terminal, and copies its output to w. Command's stdin, when set, is
+// kept as is, otherwise it reads from the pty too. Pseudo terminal is sized as our terminal, less reservedCols
+// (i.e. width of prefixes), and is resized along with it on SIGWINCH
+func startPTY(c *exec.Cmd, w io.Writer, reservedCols int) (*ptySession, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Stdin == nil {
+		c.Stdin = slave
+	}
+	c.Stdout, c.Stderr = slave, slave
+
+	// INFO: command leads a new session, with pty (its stdout) as its controlling terminal. Like a process group,
+	// session can be killed with -pid
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 1}
+
+	s := &ptySession{
+		master: master,
+		slave:  slave,
+		sigs:   make(chan os.Signal, 1),
+		stop:   make(chan struct{}),
+		copied: make(chan struct{}),
+
+		waitDelay: c.WaitDelay,
+	}
+
+	resize := func() {
+		cols, rows := 80, 24
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			cols, rows = width, height
+		}
+		ws := &unix.Winsize{Row: uint16(rows), Col: uint16(max(cols-reservedCols, 20))}
+		// INFO: master.Fd() would turn it back into blocking mode
+		if rc, err := master.SyscallConn(); err == nil {
+			_ = rc.Control(func(fd uintptr) { _ = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, ws) })
+		}
+	}
+	resize()
+
+	signal.Notify(s.sigs, syscall.SIGWINCH)
+	go func() {
+		for {
+			select {
+			case <-s.sigs:
+				resize()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(s.copied)
+		// INFO: read fails with EIO, once all of the slave ends are closed
+		_, _ = io.Copy(w, master)
+	}()
+
+	return s, nil
+}
+
+// close is called after command exits, it waits for the rest of command's output to be copied, for upto
+// waitDelay (0 means no limit), as processes that escaped command's session (e.g. with setsid) may keep the pty open
+func (s *ptySession) close() {
+	signal.Stop(s.sigs)
+	close(s.stop)
+
+	s.slave.Close()
+
+	var expired <-chan time.Time
+	if s.waitDelay > 0 {
+		t := time.NewTimer(s.waitDelay)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	select {
+	case <-s.copied:
+	case <-expired:
+	}
+	s.master.Close()
+	<-s.copied
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+	"github.com/nxtcoder17/runfile/terminal"
+	"github.com/nxtcoder17/runfile/types"
+)
+
+func Test_PTY(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		tty  bool
+		mode types.OutputMode
+		want string
+
+		stdin *types.Stdin
+	}{
+		{
+			name: "1. without tty, command gets pipes",
+			cmd:  "test -t 1 && echo tty || echo no tty",
+			tty:  false,
+			mode: types.OutputRaw,
+			want: "no tty\n",
+		},
+		{
+			name: "2. with tty, command gets a terminal, and its output is still captured",
+			cmd:  "test -t 1 && echo tty || echo no tty",
+			tty:  true,
+			mode: types.OutputRaw,
+			want: "tty\n",
+		},
+		{
+			name: "3. with tty, progress output redrawn with carriage returns, keeps only its final state",
+			cmd:  `printf '10%%\r100%%\n'`,
+			tty:  true,
+			mode: types.OutputPrefixed,
+			want: "[test] 100%\n",
+		},
+		{
+			name: "4. without tty, carriage returns are kept as is",
+			cmd:  `printf '10%%\r100%%\n'`,
+			tty:  false,
+			mode: types.OutputPrefixed,
+			want: "[test] 10%\r100%\n",
+		},
+		{
+			name:  "5. with tty, stdin of command is kept",
+			cmd:   "test -t 1 && cat",
+			tty:   true,
+			mode:  types.OutputRaw,
+			want:  "hello\n",
+			stdin: &types.Stdin{Mode: types.StdinLiteral, Value: "hello\n"},
+		},
+	}
+
+	terminal.Configure(terminal.Options{Color: terminal.ColorNever})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := shellTask("test", tt.cmd)
+			task.Groups[0].Commands[0].TTY = tt.tty
+			task.Groups[0].Commands[0].Stdin = tt.stdin
+
+			stdout := new(bytes.Buffer)
+			ex := newCmdExecutor(context.TODO(), cmdExecutorArgs{
+				Logger:     log.New(),
+				Commands:   []CommandGroup{task},
+				Stdout:     stdout,
+				Stderr:     new(bytes.Buffer),
+				OutputMode: tt.mode,
+			})
+
+			if err := ex.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			// INFO: in raw mode, pty's CRLF line endings are passed through
+			got := stdout.String()
+			if tt.mode == types.OutputRaw {
+				got = strings.ReplaceAll(got, "\r\n", "\n")
+			}
+
+			if got != tt.want {
+				t.Errorf("got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_PTYEscapedSession(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+
+	// INFO: grandchild in a session of its own, is not killed along with the command, and keeps the pty open
+	task := shellTask("test", fmt.Sprintf("setsid sh -c 'echo $$ > %s; exec sleep 300' & sleep 0.5", pidFile))
+	task.Groups[0].Commands[0].TTY = true
+
+	ex := newCmdExecutor(context.TODO(), cmdExecutorArgs{
+		Logger:          log.New(),
+		Commands:        []CommandGroup{task},
+		Stdout:          new(bytes.Buffer),
+		Stderr:          new(bytes.Buffer),
+		OutputMode:      types.OutputRaw,
+		ShutdownTimeout: 200 * time.Millisecond,
+	})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- ex.Start() }()
+
+	pid := readPid(t, pidFile)
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("task did not finish, while a grandchild holds its pty")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+)
+
+type ptySession struct{}
+
+// startPTY is only supported on linux, commands elsewhere keep running with pipes
+func startPTY(c *exec.Cmd, w io.Writer, reservedCols int) (*ptySession, error) {
+	return nil, fmt.Errorf("pty is not supported on %s", runtime.GOOS)
+}
+
+func (s *ptySession) close() {}
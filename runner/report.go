@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nxtcoder17/runfile/errors"
+)
+
+const (
+	ReportFormatJSON  = "json"
+	ReportFormatJUnit = "junit"
+)
+
+// IsValidReportFormat reports whether format is one of the supported report formats
+func IsValidReportFormat(format string) bool {
+	return format == ReportFormatJSON || format == ReportFormatJUnit
+}
+
+type jsonCommand struct {
+	Command    string    `json:"command"`
+	Status     string    `json:"status"`
+	ExitCode   int       `json:"exitCode"`
+	Ignored    bool      `json:"ignored,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Stderr     string    `json:"stderr,omitempty"`
+}
+
+type jsonTask struct {
+	Name       string        `json:"name"`
+	Status     string        `json:"status"`
+	ExitCode   int           `json:"exitCode"`
+	Ignored    bool          `json:"ignored,omitempty"`
+	StartedAt  time.Time     `json:"startedAt"`
+	DurationMs int64         `json:"durationMs"`
+	Commands   []jsonCommand `json:"commands"`
+	Tasks      []jsonTask    `json:"tasks,omitempty"`
+}
+
+type jsonReport struct {
+	Status     string     `json:"status"`
+	ExitCode   int        `json:"exitCode"`
+	StartedAt  time.Time  `json:"startedAt"`
+	DurationMs int64      `json:"durationMs"`
+	Tasks      []jsonTask `json:"tasks"`
+}
+
+func toJSONTasks(tasks []*taskNode) []jsonTask {
+	result := make([]jsonTask, 0, len(tasks))
+	for _, t := range tasks {
+		jt := jsonTask{
+			Name:       t.Name,
+			Status:     t.Status,
+			ExitCode:   t.ExitCode,
+			Ignored:    t.Ignored,
+			StartedAt:  t.StartedAt,
+			DurationMs: t.Duration.Milliseconds(),
+			Commands:   make([]jsonCommand, 0, len(t.Commands)),
+			Tasks:      toJSONTasks(t.Tasks),
+		}
+
+		for _, c := range t.Commands {
+			jt.Commands = append(jt.Commands, jsonCommand{
+				Command:    c.Command,
+				Status:     c.Status,
+				ExitCode:   c.ExitCode,
+				Ignored:    c.Ignored,
+				StartedAt:  c.StartedAt,
+				DurationMs: c.Duration.Milliseconds(),
+				Stderr:     c.Stderr,
+			})
+		}
+
+		result = append(result, jt)
+	}
+	return result
+}
+
+func (r *runReport) writeJSON(w io.Writer, startedAt time.Time, runErr error) error {
+	status, exitCode := runStatusOf(runErr)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(jsonReport{
+		Status:     status,
+		ExitCode:   exitCode,
+		StartedAt:  startedAt,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		Tasks:      toJSONTasks(taskTree(r.list())),
+	})
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit writes every task that ran commands as a testsuite, with its commands as testcases.
+// Nested tasks are named by their trail, i.e. `parent > child`
+func (r *runReport) writeJUnit(w io.Writer, startedAt time.Time) error {
+	suites := junitTestSuites{Name: "runfile", Time: junitSeconds(time.Since(startedAt))}
+
+	var walk func(tasks []*taskNode, trail []string)
+	walk = func(tasks []*taskNode, trail []string) {
+		for _, t := range tasks {
+			name := strings.Join(append(append([]string{}, trail...), t.Name), " > ")
+
+			if len(t.Commands) > 0 {
+				suite := junitTestSuite{
+					Name:      name,
+					Time:      junitSeconds(t.Duration),
+					Timestamp: t.StartedAt.Format(time.RFC3339),
+				}
+
+				for _, c := range t.Commands {
+					tc := junitTestCase{Name: firstLine(c.Command), ClassName: name, Time: junitSeconds(c.Duration)}
+					switch {
+					case c.Status == TaskStatusFailure && c.Ignored:
+						tc.SystemErr = c.Stderr
+					case c.Status == TaskStatusFailure:
+						tc.Failure = &junitFailure{Message: fmt.Sprintf("exit code %d", c.ExitCode), Type: TaskStatusFailure, Text: c.Stderr}
+						suite.Failures++
+					case c.Status != TaskStatusSuccess:
+						tc.Skipped = &junitSkipped{Message: c.Status}
+						suite.Skipped++
+					}
+					suite.TestCases = append(suite.TestCases, tc)
+				}
+
+				suite.Tests = len(suite.TestCases)
+				suites.Tests += suite.Tests
+				suites.Failures += suite.Failures
+				suites.Skipped += suite.Skipped
+				suites.TestSuites = append(suites.TestSuites, suite)
+			}
+
+			walk(t.Tasks, append(append([]string{}, trail...), t.Name))
+		}
+	}
+	walk(taskTree(r.list()), nil)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// runStatusOf returns status, and exit code of the whole run, that finished with err
+func runStatusOf(err error) (string, int) {
+	if err == nil {
+		return TaskStatusSuccess, errors.ExitCodeSuccess
+	}
+
+	exitCode := errors.ExitCode(err)
+	if exitCode == errors.ExitCodeInterrupted {
+		return TaskStatusCancelled, exitCode
+	}
+	return TaskStatusFailure, exitCode
+}
+
+// writeFile writes report of the run in format, to file
+func (r *runReport) writeFile(format string, file string, startedAt time.Time, runErr error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case ReportFormatJSON:
+		err = r.writeJSON(f, startedAt, runErr)
+	case ReportFormatJUnit:
+		err = r.writeJUnit(f, startedAt)
+	default:
+		err = fmt.Errorf("invalid report format (%s), must be one of [json,junit]", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
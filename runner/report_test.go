@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"slices"
+	"testing"
+	"time"
+)
+
+// sampleReport is a run of task `ci`, that ran `lint` and `test`, where `test` failed
+func sampleReport() *runReport {
+	r := &runReport{}
+	t0 := time.Now()
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+
+	for _, ev := range []Event{
+		{Kind: EventTaskStarted, Time: at(0), TaskID: 1, TaskName: "ci"},
+		{Kind: EventTaskStarted, Time: at(0), TaskID: 2, ParentTaskID: 1, TaskName: "lint"},
+		{Kind: EventCommandStarted, Time: at(0), TaskID: 2, TaskName: "lint", CommandID: 3, Command: "golangci-lint run"},
+		{Kind: EventCommandFinished, Time: at(100), TaskID: 2, TaskName: "lint", CommandID: 3, Command: "golangci-lint run", Status: TaskStatusSuccess},
+		{Kind: EventTaskFinished, Time: at(100), TaskID: 2, TaskName: "lint", Status: TaskStatusSuccess},
+		{Kind: EventTaskStarted, Time: at(100), TaskID: 4, ParentTaskID: 1, TaskName: "test"},
+		{Kind: EventCommandStarted, Time: at(100), TaskID: 4, TaskName: "test", CommandID: 5, Command: "go test ./..."},
+		{Kind: EventCommandFinished, Time: at(300), TaskID: 4, TaskName: "test", CommandID: 5, Command: "go test ./...", Status: TaskStatusFailure, ExitCode: 2, Stderr: "FAIL\n"},
+		{Kind: EventTaskFinished, Time: at(300), TaskID: 4, TaskName: "test", Status: TaskStatusFailure, ExitCode: 2},
+		{Kind: EventTaskFinished, Time: at(300), TaskID: 1, TaskName: "ci", Status: TaskStatusFailure, ExitCode: 2},
+	} {
+		r.emit(ev)
+	}
+	return r
+}
+
+func Test_TaskTree(t *testing.T) {
+	roots := taskTree(sampleReport().list())
+	if len(roots) != 1 || roots[0].Name != "ci" {
+		t.Fatalf("taskTree(), expected single root (ci), got %d roots", len(roots))
+	}
+
+	ci := roots[0]
+	if len(ci.Tasks) != 2 || ci.Tasks[0].Name != "lint" || ci.Tasks[1].Name != "test" {
+		t.Fatalf("taskTree(), expected (ci) to have tasks [lint, test]")
+	}
+
+	test := ci.Tasks[1]
+	if test.Status != TaskStatusFailure || test.ExitCode != 2 || test.Duration != 200*time.Millisecond {
+		t.Errorf("taskTree(), task (test) = {%s, %d, %s}, want {failure, 2, 200ms}", test.Status, test.ExitCode, test.Duration)
+	}
+
+	if len(test.Commands) != 1 || test.Commands[0].Stderr != "FAIL\n" {
+		t.Errorf("taskTree(), expected stderr of failed command to be recorded")
+	}
+}
+
+func Test_RunReportWatched(t *testing.T) {
+	r := &runReport{}
+	r.setWatched()
+
+	// INFO: `dev` is watched, and reruns, while `css` it was routed to, keeps its only run
+	for _, ev := range []Event{
+		{Kind: EventTaskStarted, TaskID: 1, TaskName: "dev"},
+		{Kind: EventTaskStarted, TaskID: 2, ParentTaskID: 1, TaskName: "build"},
+		{Kind: EventCommandStarted, TaskID: 2, TaskName: "build", CommandID: 3, Command: "go build"},
+		{Kind: EventCommandFinished, TaskID: 2, TaskName: "build", CommandID: 3, Command: "go build", Status: TaskStatusFailure, ExitCode: 1, Stderr: "FAIL\n"},
+		{Kind: EventTaskFinished, TaskID: 2, TaskName: "build", Status: TaskStatusFailure, ExitCode: 1},
+		{Kind: EventTaskFinished, TaskID: 1, TaskName: "dev", Status: TaskStatusFailure, ExitCode: 1},
+		{Kind: EventTaskStarted, TaskID: 4, TaskName: "css"},
+		{Kind: EventTaskFinished, TaskID: 4, TaskName: "css", Status: TaskStatusSuccess},
+		{Kind: EventTaskStarted, TaskID: 5, TaskName: "dev"},
+		{Kind: EventTaskStarted, TaskID: 6, ParentTaskID: 5, TaskName: "build"},
+		{Kind: EventTaskFinished, TaskID: 6, TaskName: "build", Status: TaskStatusSuccess},
+		{Kind: EventTaskFinished, TaskID: 5, TaskName: "dev", Status: TaskStatusSuccess},
+	} {
+		r.emit(ev)
+	}
+
+	var got []int
+	for _, ev := range r.list() {
+		if ev.Kind == EventTaskStarted {
+			got = append(got, ev.TaskID)
+		}
+	}
+
+	if want := []int{4, 5, 6}; !slices.Equal(got, want) {
+		t.Errorf("emit(), started tasks, that are kept, got = %v, want = %v", got, want)
+	}
+
+	if n := len(r.list()); n != 6 {
+		t.Errorf("emit(), events of previous run of (dev) must be dropped, got %d events, want 6", n)
+	}
+}
+
+func Test_WriteJSON(t *testing.T) {
+	b := new(bytes.Buffer)
+	if err := sampleReport().writeJSON(b, time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsonReport
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Tasks) != 1 || len(got.Tasks[0].Tasks) != 2 {
+		t.Fatalf("writeJSON(), expected task (ci) with 2 nested tasks, got %s", b.String())
+	}
+
+	if c := got.Tasks[0].Tasks[1].Commands[0]; c.ExitCode != 2 || c.DurationMs != 200 {
+		t.Errorf("writeJSON(), command of task (test) = {%d, %dms}, want {2, 200ms}", c.ExitCode, c.DurationMs)
+	}
+}
+
+func Test_WriteJUnit(t *testing.T) {
+	b := new(bytes.Buffer)
+	if err := sampleReport().writeJUnit(b, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Tests != 2 || got.Failures != 1 {
+		t.Errorf("writeJUnit(), tests = %d, failures = %d, want 2, 1", got.Tests, got.Failures)
+	}
+
+	// INFO: task (ci) has no commands of its own, so it is not a testsuite
+	if len(got.TestSuites) != 2 || got.TestSuites[1].Name != "ci > test" {
+		t.Fatalf("writeJUnit(), expected testsuites [ci > lint, ci > test], got %s", b.String())
+	}
+
+	tc := got.TestSuites[1].TestCases[0]
+	if tc.Failure == nil || tc.Failure.Text != "FAIL\n" || tc.Failure.Message != "exit code 2" {
+		t.Errorf("writeJUnit(), expected failure with captured stderr, got %s", b.String())
+	}
+}
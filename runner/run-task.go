@@ -8,17 +8,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
-	"sync"
 
 	"github.com/alecthomas/chroma/v2/quick"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/termenv"
-	"github.com/nxtcoder17/fwatcher/pkg/executor"
-	"github.com/nxtcoder17/fwatcher/pkg/watcher"
 	"github.com/nxtcoder17/runfile/errors"
 	fn "github.com/nxtcoder17/runfile/functions"
 	"github.com/nxtcoder17/runfile/parser"
+	"github.com/nxtcoder17/runfile/terminal"
 	"github.com/nxtcoder17/runfile/types"
 	"golang.org/x/term"
 )
@@ -28,11 +26,50 @@ type runTaskArgs struct {
 	taskName     string
 	envOverrides map[string]string
 
-	DebugEnv bool
-}
+	report *runReport
+
+	// stdout, and stderr are shared by all tasks of a run, and outputMode is the default for them
+	stdout     *LogWriter
+	stderr     *LogWriter
+	outputMode types.OutputMode
+
+	// prefixWidth is the width, task prefixes are padded to, when tasks run in parallel
+	prefixWidth int
+
+	// timestamps are added to every line of output, unless empty
+	timestamps types.TimestampMode
+
+	// tty runs commands of every task in a pseudo terminal
+	tty bool
+
+	// concurrent is true, when task runs alongside others, i.e. `run -p`
+	concurrent bool
+
+	// keepGoing lets parallel run targets, and commands of the task finish, even when one of them fails
+	keepGoing bool
+
+	// watch enables watching for the task, even when it does not have a `watch` block.
+	// watchPaths, and watchIgnore extend its watched, and ignored dirs, while watchExtensions override its extensions
+	watch           bool
+	watchPaths      []string
+	watchExtensions []string
+	watchIgnore     []string
 
-func isDarkTheme() bool {
-	return termenv.NewOutput(os.Stdout).HasDarkBackground()
+	// echo is the default echo mode, for tasks that do not set their own
+	echo types.EchoMode
+
+	// args are forwarded CLI args
+	args []string
+
+	// reload parses the Runfile again, in watch mode, when it, its includes, or dotenv files change
+	reload func() (*types.ParsedRunfile, error)
+
+	// keyboard, when set, lets watch mode be controlled with keys
+	keyboard *keyboard
+
+	scheduler *scheduler
+
+	DebugEnv bool
 }
 
 func longestLineLen(str string) int {
@@ -60,25 +97,20 @@ func padString(str string, padWith string) string {
 	return strings.Join(sp, "\n")
 }
 
-// [snippet source](https://rderik.com/blog/identify-if-output-goes-to-the-terminal-or-is-being-redirected-in-golang/)
-func isTTY() bool {
-	stdout, _ := os.Stdout.Stat()
-	stderr, _ := os.Stderr.Stat()
-	return ((stdout.Mode() & os.ModeCharDevice) == os.ModeCharDevice) && ((stderr.Mode() & os.ModeCharDevice) == os.ModeCharDevice)
-}
-
-func hasANSISupport() bool {
-	term := os.Getenv("TERM")
-	return strings.Contains(term, "xterm") || strings.Contains(term, "screen") || strings.Contains(term, "vt100") || strings.Contains(term, "tmux")
-}
-
 func printCommand(writer io.Writer, prefix, lang, cmd string) {
-	if isTTY() {
+	caps := terminal.Detect()
+	if caps.StdoutTTY && caps.StderrTTY {
 		borderColor := "#4388cc"
-		if !isDarkTheme() {
+		if !caps.DarkBackground {
 			borderColor = "#3d5485"
 		}
 
+		// INFO: color profile of lipgloss is set by terminal package, as per caps
+		formatter := "noop"
+		if caps.Color {
+			formatter = "terminal16m"
+		}
+
 		s := lipgloss.NewStyle().BorderForeground(lipgloss.Color(borderColor)).PaddingLeft(1).PaddingRight(1).Border(lipgloss.RoundedBorder(), true, true, true, true)
 
 		width := 0
@@ -88,18 +120,12 @@ func printCommand(writer io.Writer, prefix, lang, cmd string) {
 		}
 
 		hlCode := new(bytes.Buffer)
-		// choose colorschemes from `https://swapoff.org/chroma/playground/`
-		colorscheme := "catppuccin-macchiato"
-		if !isDarkTheme() {
-			colorscheme = "monokailight"
-		}
-		_ = colorscheme
 
 		longestLen := longestLineLen(cmd) + len(prefix) + 2 // 2 for spaces around prefix
 
 		cmdStr := strings.TrimSpace(cmd)
 
-		quick.Highlight(hlCode, cmdStr, lang, "terminal16m", colorscheme)
+		quick.Highlight(hlCode, cmdStr, lang, formatter, caps.Theme)
 
 		if width > 0 && longestLen >= width-2 {
 			s = s.Width(width - 2)
@@ -108,28 +134,105 @@ func printCommand(writer io.Writer, prefix, lang, cmd string) {
 	}
 }
 
+// printCommandLine prints first line of command, along with how many more lines it has
+func printCommandLine(writer io.Writer, prefix string, cmd string) {
+	cmd = strings.TrimSpace(cmd)
+
+	more := ""
+	if lines := strings.Split(cmd, "\n"); len(lines) > 1 {
+		cmd = lines[0]
+		more = fmt.Sprintf(" (+%d lines)", len(lines)-1)
+	}
+
+	fmt.Fprintf(writer, "%s%s\n", types.GetDimStyledPrefix(prefix), types.GetDimmedText([]byte("$ "+cmd+more)))
+}
+
+// resolveEcho returns the last of modes that is set, i.e. most specific one wins
+func resolveEcho(modes ...types.EchoMode) types.EchoMode {
+	result := types.EchoBox
+	for _, m := range modes {
+		if m != "" {
+			result = m
+		}
+	}
+	return result
+}
+
+// resolveStdin returns the last of stdins that is set, with its file path resolved against dir
+func resolveStdin(dir string, stdins ...*types.Stdin) *types.Stdin {
+	var result *types.Stdin
+	for _, s := range stdins {
+		if s != nil {
+			result = s
+		}
+	}
+
+	if result != nil && result.Mode == types.StdinFile && !filepath.IsAbs(result.Value) {
+		return &types.Stdin{Mode: types.StdinFile, Value: filepath.Join(dir, result.Value)}
+	}
+	return result
+}
+
 type CreateCommandGroupArgs struct {
 	Runfile *types.ParsedRunfile
 	Task    *types.ParsedTask
 
 	Trail []string
 
-	Stdout *LogWriter
-	Stderr *LogWriter
-
 	EnvOverrides map[string]string
+
+	// Args are forwarded CLI args, used only by tasks with `passArgs: true`
+	Args []string
+
+	// Echo is inherited echo mode, used when neither the task, nor its commands set one
+	Echo types.EchoMode
+
+	// TTY runs commands in a pseudo terminal, even if the task does not set `tty: true`
+	TTY bool
+
+	// Stdin is inherited stdin, used when neither the task, nor its commands set one
+	Stdin *types.Stdin
+
+	// Watched is true, when the task is run by watch mode, with ChangedFiles being the files
+	// that triggered this run, which are empty for the first one
+	Watched      bool
+	ChangedFiles []string
+}
+
+// createTaskCommandGroup creates a single command group, for the task along with its finally commands
+func createTaskCommandGroup(ctx types.Context, args CreateCommandGroupArgs) (CommandGroup, error) {
+	groups, err := createCommandGroups(ctx, args, args.Task.Commands)
+	if err != nil {
+		return CommandGroup{}, err
+	}
+
+	finally, err := createCommandGroups(ctx, args, args.Task.Finally)
+	if err != nil {
+		return CommandGroup{}, err
+	}
+
+	return CommandGroup{
+		TaskName:    args.Task.Name,
+		Groups:      groups,
+		Output:      args.Task.Output,
+		Log:         args.Task.Log,
+		Parallel:    args.Task.Parallel,
+		FailFast:    args.Task.FailFast,
+		MaxParallel: args.Task.MaxParallel,
+		Finally:     finally,
+	}, nil
 }
 
-func createCommandGroups(ctx types.Context, args CreateCommandGroupArgs) ([]executor.CommandGroup, error) {
-	var groups []executor.CommandGroup
+func createCommandGroups(ctx types.Context, args CreateCommandGroupArgs, commands []types.ParsedCommandJson) ([]CommandGroup, error) {
+	var groups []CommandGroup
 
-	for _, cmd := range args.Task.Commands {
+	for _, cmd := range commands {
 		switch {
 		case cmd.Run != nil:
 			{
 				rt, ok := args.Runfile.Tasks[*cmd.Run]
 				if !ok {
-					return nil, fmt.Errorf("invalid run target")
+					return nil, errors.ErrTaskNotFound.Wrap(fmt.Errorf("invalid run target")).KV("run-target", *cmd.Run)
 				}
 
 				rtp, err := parser.ParseTask(ctx, args.Runfile, rt)
@@ -137,26 +240,32 @@ func createCommandGroups(ctx types.Context, args CreateCommandGroupArgs) ([]exec
 					return nil, errors.WithErr(err).KV("env-vars", args.Runfile.Env)
 				}
 
-				rtCommands, err := createCommandGroups(ctx, CreateCommandGroupArgs{
+				cg, err := createTaskCommandGroup(ctx, CreateCommandGroupArgs{
 					Runfile:      args.Runfile,
 					Task:         rtp,
 					Trail:        append(append([]string{}, args.Trail...), rtp.Name),
-					Stdout:       args.Stdout,
-					Stderr:       args.Stderr,
 					EnvOverrides: cmd.Env,
+					Args:         args.Args,
+					Echo:         resolveEcho(args.Echo, args.Task.Echo, cmd.Echo),
+					TTY:          args.TTY,
+					Stdin:        resolveStdin(args.Task.WorkingDir, args.Stdin, args.Task.Stdin, cmd.Stdin),
+					Watched:      args.Watched,
+					ChangedFiles: args.ChangedFiles,
 				})
 				if err != nil {
 					return nil, errors.WithErr(err).KV("env-vars", args.Runfile.Env)
 				}
 
-				cg := executor.CommandGroup{
-					Groups:   rtCommands,
-					Parallel: rtp.Parallel,
-					PreExecCommand: func(c *exec.Cmd) {
-						str := c.String()
-						sp := strings.SplitN(str, " ", 3)
-						args.Stderr.WithDimmedPrefix(*cmd.Run).Write([]byte(sp[2]))
-					},
+				cg.IgnoreError = cmd.IgnoreError || args.Task.IgnoreError
+				// INFO: in line mode, command is already shown prefixed with its task, and in none, it must not be shown at all
+				cg.PreExecCommand = func(w io.Writer, c Command) {
+					if c.Echo != types.EchoBox {
+						return
+					}
+
+					pw := withDimmedPrefix(w, *cmd.Run)
+					pw.Write([]byte(strings.TrimSpace(c.Text)))
+					pw.Flush()
 				}
 
 				groups = append(groups, cg)
@@ -164,42 +273,82 @@ func createCommandGroups(ctx types.Context, args CreateCommandGroupArgs) ([]exec
 
 		case cmd.Command != nil:
 			{
-				cg := executor.CommandGroup{Parallel: args.Task.Parallel}
+				cg := CommandGroup{
+					TaskName:    args.Task.Name,
+					Parallel:    args.Task.Parallel,
+					IgnoreError: cmd.IgnoreError || args.Task.IgnoreError,
+					Deferred:    cmd.Defer,
+					Weight:      args.Task.Weight,
+					Priority:    args.Task.Priority,
+					Color:       args.Task.Color,
+				}
+
+				cg.PreExecCommand = func(w io.Writer, c Command) {
+					switch c.Echo {
+					case types.EchoNone:
+						return
+					case types.EchoLine:
+						printCommandLine(w, args.Task.Name, c.Text)
+					default:
+						lang := "bash"
+						if len(c.Shell) > 0 {
+							lang = c.Shell[0]
+						}
+						printCommand(w, args.Task.Name, lang, c.Text)
+					}
+				}
 
-				cg.PreExecCommand = func(cmd *exec.Cmd) {
-					str := strings.TrimSpace(cmd.String())
-					sp := strings.SplitN(str, " ", len(args.Task.Shell)+1)
+				text := *cmd.Command
+				env := fn.MapMerge(args.Task.Env, args.EnvOverrides)
 
-					lang := "bash"
-					if len(args.Task.Shell) > 0 {
-						lang = args.Task.Shell[0]
+				var cmdArgs []string
+				if args.Task.PassArgs {
+					cmdArgs = args.Args
+					env[ArgsEnvVar] = fn.ShellQuote(args.Args...)
+				}
+
+				var changedFiles []string
+				if args.Watched {
+					// INFO: changed files are relative to the task's working dir, so that they can be passed to tools as is
+					// and when they can not be, e.g. on another volume, they are passed as they are
+					for _, f := range args.ChangedFiles {
+						if rel, err := filepath.Rel(args.Task.WorkingDir, f); err == nil {
+							f = rel
+						}
+						changedFiles = append(changedFiles, f)
 					}
-					printCommand(args.Stderr, args.Task.Name, lang, sp[2])
+					env[ChangedFilesEnvVar] = strings.Join(changedFiles, "\n")
 				}
 
-				cg.Commands = append(
-					cg.Commands,
-					func(c context.Context) *exec.Cmd {
-						return CreateCommand(ctx, CmdArgs{
+				if args.Task.Template {
+					rendered, err := renderCommand(text, cmdArgs, changedFiles)
+					if err != nil {
+						return nil, errors.ErrTaskInvalidCommand.Wrap(err).KV("task", args.Task.Name, "command", text)
+					}
+					text = rendered
+				}
+
+				cg.Commands = append(cg.Commands, Command{
+					Shell: args.Task.Shell,
+					Text:  text,
+					Args:  cmdArgs,
+					Echo:  resolveEcho(args.Echo, args.Task.Echo, cmd.Echo),
+					TTY:   args.TTY || args.Task.TTY,
+					Stdin: resolveStdin(args.Task.WorkingDir, args.Stdin, args.Task.Stdin, cmd.Stdin),
+					Create: func(c context.Context) *exec.Cmd {
+						return CreateCommand(c, CmdArgs{
 							Shell:       args.Task.Shell,
-							Env:         fn.ToEnviron(fn.MapMerge(args.Task.Env, args.EnvOverrides)),
-							Cmd:         *cmd.Command,
+							Env:         fn.ToEnviron(env),
+							Cmd:         text,
+							Args:        cmdArgs,
 							WorkingDir:  args.Task.WorkingDir,
 							interactive: args.Task.Interactive,
-							Stdout: func() io.Writer {
-								if args.Task.Interactive {
-									return os.Stdout
-								}
-								return args.Stdout.WithPrefix(args.Task.Name)
-							}(),
-							Stderr: func() io.Writer {
-								if args.Task.Interactive {
-									return os.Stderr
-								}
-								return args.Stderr.WithPrefix(args.Task.Name)
-							}(),
+							// INFO: output of non interactive commands is set by executor, as per task's output mode
+							Stdout: os.Stdout,
+							Stderr: os.Stderr,
 						})
-					})
+					},
+				})
 
 				ctx.Debug("HERE", "cmd", *cmd.Command, "parallel", args.Task.Parallel)
 
@@ -230,81 +379,250 @@ func runTask(ctx types.Context, prf *types.ParsedRunfile, args runTaskArgs) erro
 		return errors.WithErr(err)
 	}
 
-	logStdout := &LogWriter{w: os.Stdout}
+	if len(args.args) > 0 && !pt.PassArgs {
+		logger.Warn("task does not accept args, set `passArgs: true` on it, to forward them", "args", args.args)
+	}
+
+	watch := resolveWatch(pt, *task.Metadata.RunfilePath, args)
+
+	build := func(prf *types.ParsedRunfile, t *types.ParsedTask, changedFiles []string) (CommandGroup, error) {
+		return createTaskCommandGroup(ctx, CreateCommandGroupArgs{
+			Runfile:      prf,
+			Task:         t,
+			Trail:        []string{t.Name},
+			Args:         args.args,
+			Echo:         args.echo,
+			TTY:          args.tty,
+			Watched:      watch != nil,
+			ChangedFiles: changedFiles,
+		})
+	}
 
-	execCommands, err := createCommandGroups(ctx, CreateCommandGroupArgs{
-		Runfile: prf,
-		Task:    pt,
-		Trail:   []string{pt.Name},
-		Stdout:  logStdout,
-		Stderr:  logStdout,
-	})
+	taskGroup, err := build(prf, pt, nil)
 	if err != nil {
 		return err
 	}
 
-	ctx.Debug("top level command groups", "len", len(execCommands))
+	ctx.Debug("top level command groups", "len", len(taskGroup.Groups), "finally", len(taskGroup.Finally))
 
-	for i := range execCommands {
-		ctx.Debug("debugging execCommands", "i", execCommands[i].Parallel)
+	if ctx.DryRun {
+		printPlan(os.Stdout, buildPlan(ctx, taskGroup), "", true, true)
+		return nil
 	}
 
-	ex := executor.NewCmdExecutor(ctx, executor.CmdExecutorArgs{
+	exArgs := cmdExecutorArgs{
 		Logger:      logger,
-		Interactive: pt.Interactive,
-		Commands:    execCommands,
-		Parallel:    pt.Parallel,
-	})
-
-	switch pt.Watch == nil {
-	case true:
-		{
-			if err := ex.Start(); err != nil {
-				logger.Error(err, "while running command")
-				return err
-			}
-			logger.Debug("completed")
+		Commands:    []CommandGroup{taskGroup},
+		Report:      args.report,
+		Stdout:      args.stdout,
+		Stderr:      args.stderr,
+		OutputMode:  args.outputMode,
+		Timestamps:  args.timestamps,
+		Concurrent:  args.concurrent,
+		PrefixWidth: args.prefixWidth,
+		Scheduler:   args.scheduler,
+		KeepGoing:   args.keepGoing,
+	}
+
+	if watch == nil {
+		if err := newCmdExecutor(ctx, exArgs).Start(); err != nil {
+			logger.Debug("while running command", "err", err)
+			return err
 		}
-	case false:
-		{
-			var wg sync.WaitGroup
-			if pt.Watch != nil && (pt.Watch.Enable == nil || *pt.Watch.Enable) {
-				watch, err := watcher.NewWatcher(ctx, watcher.WatcherArgs{
-					Logger:               logger,
-					WatchDirs:            append(task.Watch.Dirs, pt.WorkingDir),
-					IgnoreDirs:           task.Watch.IgnoreDirs,
-					WatchExtensions:      pt.Watch.Extensions,
-					IgnoreExtensions:     pt.Watch.IgnoreExtensions,
-					IgnoreList:           watcher.DefaultIgnoreList,
-					Interactive:          pt.Interactive,
-					ShouldLogWatchEvents: false,
-				})
-				if err != nil {
-					return errors.WithErr(err)
-				}
+		logger.Debug("completed")
+		return nil
+	}
 
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					<-ctx.Done()
-					logger.Info("fwatcher is closing ...")
-					watch.Close()
-				}()
+	args.report.setWatched()
 
-				executors := []executor.Executor{ex}
+	// INFO: on reload, both prf, and parsed tasks are replaced, and targets build their commands from them
+	parsed := map[string]*types.ParsedTask{args.taskName: pt}
+	parseRoutes := func(prf *types.ParsedRunfile, watch *types.TaskWatch, parsed map[string]*types.ParsedTask) error {
+		for _, name := range watch.On {
+			if _, ok := parsed[name]; ok {
+				continue
+			}
 
-				if task.Watch.SSE != nil && task.Watch.SSE.Addr != "" {
-					executors = append(executors, executor.NewSSEExecutor(executor.SSEExecutorArgs{Addr: task.Watch.SSE.Addr}))
-				}
+			rt, ok := prf.Tasks[name]
+			if !ok {
+				return errors.ErrTaskNotFound.Wrap(fmt.Errorf("invalid watch route")).KV("run-target", name)
+			}
 
-				if err := watch.WatchAndExecute(ctx, executors); err != nil {
-					return err
-				}
+			rpt, err := parser.ParseTask(ctx, prf, rt)
+			if err != nil {
+				return errors.WithErr(err)
 			}
+			parsed[name] = rpt
+		}
+		return nil
+	}
+
+	if err := parseRoutes(prf, watch, parsed); err != nil {
+		return err
+	}
+
+	// newLoop creates watch loop, with its targets, and the watcher of their files, as per watch settings
+	newLoop := func(pt *types.ParsedTask, watch *types.TaskWatch) (*watchLoop, *fileWatcher, error) {
+		exArgs := exArgs
+		exArgs.ShutdownTimeout = types.DurationOr(watch.ShutdownTimeout, DefaultShutdownTimeout)
+		// INFO: routed tasks may run alongside the watched one
+		exArgs.Concurrent = exArgs.Concurrent || len(watch.On) > 0
+
+		target := func(name string) *watchTarget {
+			return &watchTarget{
+				name:     name,
+				executor: newCmdExecutor(ctx, exArgs),
+				commands: func(changedFiles []string) ([]CommandGroup, error) {
+					cg, err := build(prf, parsed[name], changedFiles)
+					if err != nil {
+						return nil, err
+					}
+					return []CommandGroup{cg}, nil
+				},
+			}
+		}
+
+		filter := newWatchFilter(pt.WorkingDir, watch)
+		fw, err := newFileWatcher(logger, filter)
+		if err != nil {
+			return nil, nil, errors.WithErr(err)
+		}
+
+		wl := &watchLoop{
+			logger:     logger,
+			task:       target(args.taskName),
+			routes:     make(map[string]*watchTarget),
+			route:      filter.routes,
+			debounce:   types.DurationOr(watch.Debounce, DefaultWatchDebounce),
+			runOnStart: fn.DefaultIfNil(watch.RunOnStart, true),
+			policy:     watch.Policy,
+		}
+
+		for _, name := range watch.On {
+			wl.routes[name] = target(name)
+		}
+		return wl, fw, nil
+	}
+
+	// acquireSSE returns the server, that watch settings publish events on, nil when they have none
+	acquireSSE := func(watch *types.TaskWatch) (*sseServer, error) {
+		if watch.SSE == nil || watch.SSE.Addr == "" {
+			return nil, nil
+		}
+		sse, err := acquireSSEServer(watch.SSE.Addr)
+		if err != nil {
+			return nil, errors.WithErr(err).KV("sse", watch.SSE.Addr)
+		}
+		return sse, nil
+	}
+
+	changes, reloads := make(chan string), make(chan string)
+
+	// INFO: on reload, with changed watch settings, file watcher is replaced, and the replaced one stops sending changes
+	stopWatching := func() {}
+	watchFiles := func(fw *fileWatcher) {
+		wctx, cancel := context.WithCancel(ctx)
+		go fw.run(wctx, changes, reloads)
+		stopWatching = func() {
+			cancel()
+			fw.Close()
+		}
+	}
+	defer func() { stopWatching() }()
+
+	wl, fw, err := newLoop(pt, watch)
+	if err != nil {
+		return err
+	}
+
+	if err := fw.watchConfig(prf.Metadata.Files); err != nil {
+		fw.Close()
+		return errors.WithErr(err)
+	}
+	watchFiles(fw)
+
+	sse, err := acquireSSE(watch)
+	if err != nil {
+		return err
+	}
+	defer func() { sse.release() }()
+	wl.sse = sse
 
-			wg.Wait()
+	wl.reload = func() (*watchLoop, error) {
+		nprf, err := args.reload()
+		if err != nil {
+			return nil, err
 		}
+
+		task, ok := nprf.Tasks[args.taskName]
+		if !ok {
+			return nil, errors.ErrTaskNotFound.KV("task", args.taskName)
+		}
+
+		npt, err := parser.ParseTask(ctx, nprf, task)
+		if err != nil {
+			return nil, errors.WithErr(err)
+		}
+
+		nwatch := resolveWatch(npt, *task.Metadata.RunfilePath, args)
+		if nwatch == nil {
+			return nil, fmt.Errorf("watch is disabled for the task, restart run to stop watching")
+		}
+
+		nparsed := map[string]*types.ParsedTask{args.taskName: npt}
+		if err := parseRoutes(nprf, nwatch, nparsed); err != nil {
+			return nil, err
+		}
+
+		// INFO: commands are created once, so that their errors show up before anything is replaced
+		if _, err := build(nprf, npt, nil); err != nil {
+			return nil, err
+		}
+
+		if reflect.DeepEqual(nwatch, watch) {
+			prf, parsed = nprf, nparsed
+			return nil, fw.watchConfig(nprf.Metadata.Files)
+		}
+
+		next, nfw, err := newLoop(npt, nwatch)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := nfw.watchConfig(nprf.Metadata.Files); err != nil {
+			nfw.Close()
+			return nil, errors.WithErr(err)
+		}
+
+		// INFO: a server on the same address is shared, so it keeps running across the swap
+		nsse, err := acquireSSE(nwatch)
+		if err != nil {
+			nfw.Close()
+			return nil, err
+		}
+		sse.release()
+		sse, next.sse = nsse, nsse
+
+		stopWatching()
+		watchFiles(nfw)
+		fw = nfw
+
+		prf, parsed, watch = nprf, nparsed, nwatch
+		return next, nil
+	}
+
+	// INFO: interactive tasks read from the terminal themselves
+	var actions <-chan watchAction
+	if args.keyboard != nil && !pt.Interactive {
+		if actions = args.keyboard.subscribe(); actions == nil {
+			logger.Debug("keys are not available", "err", args.keyboard.err)
+		}
+	}
+
+	if err := wl.run(ctx, changes, reloads, actions); err != nil {
+		return err
 	}
+	logger.Debug("stopped watching")
 
 	return nil
 }
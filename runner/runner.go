@@ -2,15 +2,19 @@ package runner
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/nxtcoder17/runfile/errors"
 	fn "github.com/nxtcoder17/runfile/functions"
+	"github.com/nxtcoder17/runfile/parser"
+	"github.com/nxtcoder17/runfile/terminal"
 	"github.com/nxtcoder17/runfile/types"
-	"golang.org/x/sync/errgroup"
 )
 
 type CmdArgs struct {
@@ -20,6 +24,9 @@ type CmdArgs struct {
 
 	Cmd string
 
+	// Args are appended to the interpreter invocation, i.e. they are "$@" for posix shells
+	Args []string
+
 	interactive bool
 	Stdout      io.Writer
 	Stderr      io.Writer
@@ -40,7 +47,15 @@ func CreateCommand(ctx context.Context, args CmdArgs) *exec.Cmd {
 
 	shell := args.Shell[0]
 
-	cargs := append(args.Shell[1:], args.Cmd)
+	cargs := append(append([]string{}, args.Shell[1:]...), args.Cmd)
+	if len(args.Args) > 0 {
+		if isPosixShell(shell) {
+			// INFO: with `sh -c script`, first arg after script is $0, and rest are $@
+			cargs = append(cargs, shell)
+		}
+		cargs = append(cargs, args.Args...)
+	}
+
 	c := exec.CommandContext(ctx, shell, cargs...)
 	c.Dir = args.WorkingDir
 	c.Env = args.Env
@@ -48,7 +63,10 @@ func CreateCommand(ctx context.Context, args CmdArgs) *exec.Cmd {
 	c.Stderr = args.Stderr
 
 	if args.interactive {
+		// INFO: interactive commands must stay in terminal's foreground process group, to be able to read from it
 		c.Stdin = os.Stdin
+	} else {
+		setProcessGroup(c, DefaultShutdownTimeout)
 	}
 
 	return c
@@ -60,16 +78,57 @@ type RunArgs struct {
 	Watch             bool
 	Debug             bool
 	KVs               map[string]string
+
+	// WatchPaths, and WatchIgnore extend watched, and ignored dirs of every task, in watch mode.
+	// WatchExtensions override extensions, they watch
+	WatchPaths      []string
+	WatchExtensions []string
+	WatchIgnore     []string
+
+	// KeepGoing continues running the remaining tasks, even after one of them fails. In parallel mode, and for
+	// tasks with `parallel: true`, it overrides FailFast
+	KeepGoing bool
+
+	// FailFast, in parallel mode, cancels the other tasks as soon as one of them fails.
+	// When false, all tasks are waited for
+	FailFast bool
+
+	// Args are CLI args after `--`, forwarded to tasks with `passArgs: true`
+	Args []string
+
+	// Output is the default output mode for tasks, that do not set their own. Default: prefixed
+	Output types.OutputMode
+
+	// Timestamps are added to every line of output, either absolute, or relative to task start, unless empty
+	Timestamps types.TimestampMode
+
+	// TTY runs commands of every task in a pseudo terminal
+	TTY bool
+
+	// Echo is the default echo mode for tasks, that do not set their own. Default: box
+	Echo types.EchoMode
+
+	// ReportFormat, when set, writes report of the run to ReportFile, in either json or junit format
+	ReportFormat string
+	ReportFile   string
+
+	// MaxParallel limits the number of commands running at once, across all tasks, 0 means no limit
+	MaxParallel int
 }
 
-func Run(ctx types.Context, prf *types.ParsedRunfile, args RunArgs) error {
-	// INFO: adding parsed KVs as environments to the specified tasks
-	for k, v := range args.KVs {
+// withKVs adds KVs from CLI, as env vars of all tasks
+func withKVs(prf *types.ParsedRunfile, kvs map[string]string) {
+	for k, v := range kvs {
 		if prf.Env == nil {
 			prf.Env = make(map[string]string)
 		}
 		prf.Env[k] = v
 	}
+}
+
+func Run(ctx types.Context, prf *types.ParsedRunfile, args RunArgs) (err error) {
+	// INFO: adding parsed KVs as environments to the specified tasks
+	withKVs(prf, args.KVs)
 
 	attr := func(taskName string) []any {
 		return []any{
@@ -84,14 +143,84 @@ func Run(ctx types.Context, prf *types.ParsedRunfile, args RunArgs) error {
 		}
 	}
 
-	if args.ExecuteInParallel {
+	report := &runReport{captureStderr: args.ReportFormat != ""}
+	defer func() {
+		// INFO: tasks may be watched by their `watch` block, even without `-w`
+		if !report.isWatched() {
+			report.printSummary(os.Stderr)
+		}
+	}()
+
+	if args.ReportFormat != "" {
+		startedAt := time.Now()
+		defer func() {
+			if werr := report.writeFile(args.ReportFormat, args.ReportFile, startedAt, err); werr != nil {
+				if err == nil {
+					err = fmt.Errorf("failed to write report (%s): %w", args.ReportFile, werr)
+					return
+				}
+				ctx.Warn("failed to write report", "file", args.ReportFile, "err", werr)
+			}
+		}()
+	}
+
+	sched := newScheduler(args.MaxParallel)
+	stdout, stderr := &LogWriter{w: os.Stdout}, &LogWriter{w: os.Stderr}
+
+	// INFO: keys are read only once a task gets watched, and never when a task needs the terminal for itself
+	var kb *keyboard
+	if terminal.Detect().StdinTTY && !ctx.DryRun && !slices.ContainsFunc(args.Tasks, func(tn string) bool { return prf.Tasks[tn].Interactive }) {
+		kb = newKeyboard(os.Stdin, stdout, stderr)
+		defer kb.close()
+	}
+
+	base := runTaskArgs{
+		report:          report,
+		stdout:          stdout,
+		stderr:          stderr,
+		outputMode:      args.Output,
+		echo:            args.Echo,
+		tty:             args.TTY,
+		timestamps:      args.Timestamps,
+		keepGoing:       args.KeepGoing,
+		watch:           args.Watch,
+		watchPaths:      args.WatchPaths,
+		watchExtensions: args.WatchExtensions,
+		watchIgnore:     args.WatchIgnore,
+		args:            args.Args,
+		scheduler:       sched,
+		keyboard:        kb,
+		reload: func() (*types.ParsedRunfile, error) {
+			nprf, err := parser.ParseRunfile(ctx, prf.Metadata.RunfilePath)
+			if err != nil {
+				return nil, err
+			}
+			withKVs(nprf, args.KVs)
+			return nprf, nil
+		},
+	}
+
+	// INFO: in watch mode, tasks never finish, so they are all watched at once
+	concurrent := args.ExecuteInParallel || (args.Watch && len(args.Tasks) > 1)
+
+	// INFO: in dry run, plans are printed one after the other, even for parallel tasks
+	if concurrent && !ctx.DryRun {
 		ctx.Debug("running in parallel mode", "tasks", args.Tasks)
-		g := new(errgroup.Group)
+		prefixWidth := 0
+		for _, tn := range args.Tasks {
+			prefixWidth = max(prefixWidth, len(tn))
+		}
+
+		g, gctx := newGroup(ctx, args.FailFast && !args.KeepGoing)
+		tctx := ctx
+		tctx.Context = gctx
 
 		for _, _tn := range args.Tasks {
 			tn := _tn
 			g.Go(func() error {
-				if err := runTask(ctx, prf, runTaskArgs{taskName: tn}); err != nil {
+				ta := base
+				ta.taskName, ta.concurrent, ta.prefixWidth = tn, true, prefixWidth
+				if err := runTask(tctx, prf, ta); err != nil {
 					return errors.WithErr(err).KV(attr(tn)...)
 				}
 				return nil
@@ -106,11 +235,21 @@ func Run(ctx types.Context, prf *types.ParsedRunfile, args RunArgs) error {
 		return nil
 	}
 
+	var firstErr error
 	for _, tn := range args.Tasks {
-		if err := runTask(ctx, prf, runTaskArgs{taskName: tn, DebugEnv: false}); err != nil {
-			return errors.WithErr(err).KV(attr(tn)...)
+		ta := base
+		ta.taskName = tn
+		if err := runTask(ctx, prf, ta); err != nil {
+			if !args.KeepGoing {
+				return errors.WithErr(err).KV(attr(tn)...)
+			}
+
+			ctx.Debug("task failed, keep going", "task", tn, "err", err)
+			if firstErr == nil {
+				firstErr = errors.WithErr(err).KV(attr(tn)...)
+			}
 		}
 	}
 
-	return nil
+	return firstErr
 }
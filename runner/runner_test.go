@@ -0,0 +1,326 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+	"github.com/nxtcoder17/runfile/errors"
+	"github.com/nxtcoder17/runfile/parser"
+	"github.com/nxtcoder17/runfile/types"
+)
+
+// runRunfile writes runfile into a temp dir, and runs it from there, so that its commands run in that dir too
+func runRunfile(t *testing.T, runfile string, args RunArgs) (dir string, err error) {
+	t.Helper()
+
+	dir = t.TempDir()
+	t.Chdir(dir)
+	file := filepath.Join(dir, "Runfile.yml")
+	if err := os.WriteFile(file, []byte(runfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := types.NewContext(t.Context(), log.New())
+	prf, err := parser.ParseRunfile(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args.Output == "" {
+		args.Output = types.OutputQuiet
+	}
+
+	return dir, Run(ctx, prf, args)
+}
+
+func exists(dir string, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func Test_RunParallel(t *testing.T) {
+	runfile := strings.TrimSpace(`
+tasks:
+  fails:
+    cmd:
+      - exit 3
+  slow:
+    cmd:
+      - sleep 0.3
+      - touch slow.done
+  all:
+    parallel: true
+    cmd:
+      - exit 3
+      - sleep 0.3 && touch slow.done
+`)
+
+	tests := []struct {
+		name         string
+		args         RunArgs
+		wantSlowDone bool
+	}{
+		{
+			name:         "1. fail fast cancels other tasks",
+			args:         RunArgs{Tasks: []string{"fails", "slow"}, ExecuteInParallel: true, FailFast: true},
+			wantSlowDone: false,
+		},
+		{
+			name:         "2. keep going lets other tasks finish, even with fail fast",
+			args:         RunArgs{Tasks: []string{"fails", "slow"}, ExecuteInParallel: true, FailFast: true, KeepGoing: true},
+			wantSlowDone: true,
+		},
+		{
+			name:         "3. failing command of a parallel task cancels its other commands",
+			args:         RunArgs{Tasks: []string{"all"}},
+			wantSlowDone: false,
+		},
+		{
+			name:         "4. keep going lets other commands of a parallel task finish",
+			args:         RunArgs{Tasks: []string{"all"}, KeepGoing: true},
+			wantSlowDone: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := runRunfile(t, runfile, tt.args)
+			if err == nil {
+				t.Fatalf("Run(), must fail, as a command exits with 3")
+			}
+
+			if got := errors.ExitCode(err); got != 3 {
+				t.Errorf("Run(), exit code, got = %d, want = 3", got)
+			}
+
+			if got := exists(dir, "slow.done"); got != tt.wantSlowDone {
+				t.Errorf("Run(), task (slow) finished, got = %v, want = %v", got, tt.wantSlowDone)
+			}
+		})
+	}
+}
+
+func Test_RunIgnoreError(t *testing.T) {
+	tests := []struct {
+		name     string
+		runfile  string
+		wantErr  bool
+		wantDone bool
+	}{
+		{
+			name: "1. command with ignoreError, lets later commands run",
+			runfile: `
+tasks:
+  lint:
+    cmd:
+      - cmd: exit 2
+        ignoreError: true
+      - touch lint.done
+`,
+			wantErr:  false,
+			wantDone: true,
+		},
+		{
+			name: "2. task with ignoreError, continues past all of its failing commands",
+			runfile: `
+tasks:
+  lint:
+    ignoreError: true
+    cmd:
+      - exit 2
+      - exit 3
+      - touch lint.done
+`,
+			wantErr:  false,
+			wantDone: true,
+		},
+		{
+			name: "3. failing command, without ignoreError, stops the task",
+			runfile: `
+tasks:
+  lint:
+    cmd:
+      - exit 2
+      - touch lint.done
+`,
+			wantErr:  true,
+			wantDone: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := runRunfile(t, strings.TrimSpace(tt.runfile), RunArgs{Tasks: []string{"lint"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run(), error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := exists(dir, "lint.done"); got != tt.wantDone {
+				t.Errorf("Run(), later command ran, got = %v, want = %v", got, tt.wantDone)
+			}
+		})
+	}
+}
+
+func Test_RunKeepGoing(t *testing.T) {
+	runfile := strings.TrimSpace(`
+tasks:
+  a:
+    cmd:
+      - exit 4
+  b:
+    cmd:
+      - touch b.done
+`)
+
+	tests := []struct {
+		name      string
+		keepGoing bool
+		wantBDone bool
+	}{
+		{
+			name:      "1. without keep going, first failing task stops the run",
+			keepGoing: false,
+			wantBDone: false,
+		},
+		{
+			name:      "2. with keep going, remaining tasks run, and run still fails",
+			keepGoing: true,
+			wantBDone: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := runRunfile(t, runfile, RunArgs{Tasks: []string{"a", "b"}, KeepGoing: tt.keepGoing})
+			if got := errors.ExitCode(err); got != 4 {
+				t.Errorf("Run(), exit code, got = %d, want = 4", got)
+			}
+
+			if got := exists(dir, "b.done"); got != tt.wantBDone {
+				t.Errorf("Run(), task (b) ran, got = %v, want = %v", got, tt.wantBDone)
+			}
+		})
+	}
+}
+
+func Test_RunPassArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		runfile string
+		args    []string
+		want    string
+	}{
+		{
+			name: "1. braces, that are not meant as templates, pass through unchanged",
+			runfile: `
+tasks:
+  ps:
+    passArgs: true
+    cmd:
+      - echo '{{.Names}}' "$@" > out.txt
+`,
+			args: []string{"-a"},
+			want: "{{.Names}} -a\n",
+		},
+		{
+			name: "2. with template: true, args are rendered into commands",
+			runfile: `
+tasks:
+  ps:
+    passArgs: true
+    template: true
+    cmd:
+      - echo {{ .Args }} > out.txt
+`,
+			args: []string{"hello world"},
+			want: "hello world\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := runRunfile(t, strings.TrimSpace(tt.runfile), RunArgs{Tasks: []string{"ps"}, Args: tt.args})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Run(), output, got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_RunMaxParallel(t *testing.T) {
+	// INFO: every command records how many commands are running, as it starts
+	const record = "mkdir -p running && touch running/$$ && ls running | wc -l >> counts && sleep 0.2 && rm running/$$"
+
+	tests := []struct {
+		name        string
+		runfile     string
+		maxParallel int
+		wantMax     int
+	}{
+		{
+			name: "1. maxParallel limits run targets of a parallel task",
+			runfile: `
+tasks:
+  all:
+    parallel: true
+    maxParallel: 2
+    cmd:
+      - run: child
+      - run: child
+      - run: child
+      - run: child
+  child:
+    cmd:
+      - ` + record,
+			wantMax: 2,
+		},
+		{
+			name: "2. -j limits commands, across maxParallel of tasks",
+			runfile: `
+tasks:
+  all:
+    parallel: true
+    maxParallel: 3
+    cmd:
+      - ` + record + `
+      - ` + record + `
+      - ` + record,
+			maxParallel: 1,
+			wantMax:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := runRunfile(t, strings.TrimSpace(tt.runfile), RunArgs{Tasks: []string{"all"}, MaxParallel: tt.maxParallel})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, "counts"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, c := range strings.Fields(string(b)) {
+				if n, _ := strconv.Atoi(c); n > tt.wantMax {
+					t.Errorf("Run(), commands running at once, got = %d, want at most %d", n, tt.wantMax)
+				}
+			}
+		})
+	}
+}
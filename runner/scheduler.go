@@ -0,0 +1,180 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// scheduler limits the number of commands running at once, across all tasks of a run.
+// Commands take as many slots as their weight, and when slots are contended, waiters with higher
+// priority get them first. It also owns limits of tasks (i.e. `maxParallel`) as slot pools, so that
+// a command is admitted only when both, the scheduler, and its task's pool have room for it
+type scheduler struct {
+	mu       sync.Mutex
+	capacity int
+	used     int
+
+	seq     uint64
+	waiters []*slotWaiter
+}
+
+// slotPool limits how many commands, or run targets of a parallel task run at once
+type slotPool struct {
+	limit int
+	used  int
+}
+
+type slotWaiter struct {
+	pool     *slotPool
+	weight   int
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+// newScheduler creates a scheduler with capacity slots, capacity <= 0 means no limit
+func newScheduler(capacity int) *scheduler {
+	return &scheduler{capacity: capacity}
+}
+
+// newPool creates a pool of limit slots, limit <= 0 means no limit, for which it returns nil
+func (s *scheduler) newPool(limit int) *slotPool {
+	if limit <= 0 {
+		return nil
+	}
+	return &slotPool{limit: limit}
+}
+
+// weightOf clamps weight, such that a command never needs more slots than there are
+func (s *scheduler) weightOf(weight int) int {
+	if weight < 1 {
+		weight = 1
+	}
+	if s.capacity > 0 && weight > s.capacity {
+		weight = s.capacity
+	}
+	return weight
+}
+
+// acquire blocks until weight slots of the scheduler, and a slot of pool (when not nil) are available, or ctx is done
+func (s *scheduler) acquire(ctx context.Context, pool *slotPool, weight int, priority int) error {
+	if s == nil {
+		return nil
+	}
+	return s.wait(ctx, pool, s.weightOf(weight), priority)
+}
+
+func (s *scheduler) release(pool *slotPool, weight int) {
+	if s == nil {
+		return
+	}
+	s.put(pool, s.weightOf(weight))
+}
+
+// acquirePool blocks until a slot of pool is available, or ctx is done. It is for run targets, which
+// take no slots of the scheduler themselves, as only commands do, so that nested tasks can never deadlock
+func (s *scheduler) acquirePool(ctx context.Context, pool *slotPool, priority int) error {
+	if s == nil {
+		return nil
+	}
+	return s.wait(ctx, pool, 0, priority)
+}
+
+func (s *scheduler) releasePool(pool *slotPool) {
+	if s == nil {
+		return
+	}
+	s.put(pool, 0)
+}
+
+func (s *scheduler) unlimited(pool *slotPool, weight int) bool {
+	return pool == nil && (s.capacity <= 0 || weight == 0)
+}
+
+func (s *scheduler) wait(ctx context.Context, pool *slotPool, weight int, priority int) error {
+	if s.unlimited(pool, weight) {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.seq++
+	w := &slotWaiter{pool: pool, weight: weight, priority: priority, seq: s.seq, ready: make(chan struct{})}
+	idx, _ := slices.BinarySearchFunc(s.waiters, w, compareWaiters)
+	s.waiters = slices.Insert(s.waiters, idx, w)
+	s.notify()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		select {
+		case <-w.ready:
+			// INFO: slots were granted, just as ctx got done
+			s.free(w.pool, w.weight)
+			s.notify()
+		default:
+			s.waiters = slices.DeleteFunc(s.waiters, func(item *slotWaiter) bool { return item == w })
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *scheduler) put(pool *slotPool, weight int) {
+	if s.unlimited(pool, weight) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.free(pool, weight)
+	s.notify()
+}
+
+// free returns slots, it must be called with s.mu held
+func (s *scheduler) free(pool *slotPool, weight int) {
+	if s.capacity > 0 {
+		s.used -= weight
+	}
+	if pool != nil {
+		pool.used--
+	}
+}
+
+// notify grants slots to waiters in order, it must be called with s.mu held. Waiters whose pool
+// is full are skipped, as they do not hold back others, but the first one that needs more slots
+// of the scheduler than are free, stops the rest, so that it is not starved by lighter ones
+func (s *scheduler) notify() {
+	for i := 0; i < len(s.waiters); {
+		w := s.waiters[i]
+		if w.pool != nil && w.pool.used >= w.pool.limit {
+			i++
+			continue
+		}
+
+		if s.capacity > 0 && s.used+w.weight > s.capacity {
+			return
+		}
+
+		if s.capacity > 0 {
+			s.used += w.weight
+		}
+		if w.pool != nil {
+			w.pool.used++
+		}
+		s.waiters = slices.Delete(s.waiters, i, i+1)
+		close(w.ready)
+	}
+}
+
+// compareWaiters orders waiters by higher priority first, and then by arrival
+func compareWaiters(a, b *slotWaiter) int {
+	if a.priority != b.priority {
+		return b.priority - a.priority
+	}
+	return int(a.seq) - int(b.seq)
+}
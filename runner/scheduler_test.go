@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_SchedulerLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		pool     int
+		weight   int
+		workers  int
+		wantMax  int
+	}{
+		{
+			name:     "1. at most capacity commands run at once",
+			capacity: 2,
+			weight:   1,
+			workers:  6,
+			wantMax:  2,
+		},
+		{
+			name:     "2. heavy commands take more slots",
+			capacity: 4,
+			weight:   2,
+			workers:  6,
+			wantMax:  2,
+		},
+		{
+			name:     "3. weight more than capacity, is clamped to capacity",
+			capacity: 2,
+			weight:   5,
+			workers:  3,
+			wantMax:  1,
+		},
+		{
+			name:     "4. pool limits commands of a task, even without a limit on the run",
+			capacity: 0,
+			pool:     2,
+			weight:   1,
+			workers:  6,
+			wantMax:  2,
+		},
+		{
+			name:     "5. pool, and the run's limit apply together, and the lower one wins",
+			capacity: 1,
+			pool:     3,
+			weight:   1,
+			workers:  6,
+			wantMax:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScheduler(tt.capacity)
+			pool := s.newPool(tt.pool)
+
+			var mu sync.Mutex
+			running, maxRunning := 0, 0
+
+			var wg sync.WaitGroup
+			for range tt.workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := s.acquire(context.TODO(), pool, tt.weight, 0); err != nil {
+						t.Error(err)
+						return
+					}
+					defer s.release(pool, tt.weight)
+
+					mu.Lock()
+					running++
+					maxRunning = max(maxRunning, running)
+					mu.Unlock()
+
+					time.Sleep(20 * time.Millisecond)
+
+					mu.Lock()
+					running--
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			if maxRunning != tt.wantMax {
+				t.Errorf("max running commands = %d, want %d", maxRunning, tt.wantMax)
+			}
+		})
+	}
+}
+
+func Test_SchedulerPriority(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.TODO(), nil, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []int
+
+	var wg sync.WaitGroup
+	for i, priority := range []int{1, 5, 3} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.acquire(context.TODO(), nil, 1, priority); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+			s.release(nil, 1)
+		}()
+		// INFO: waiting for it to be queued, so that arrival order is deterministic
+		for {
+			s.mu.Lock()
+			n := len(s.waiters)
+			s.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	s.release(nil, 1)
+	wg.Wait()
+
+	want := []int{5, 3, 1}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("order of acquiring slots = %v, want %v", order, want)
+		}
+	}
+}
+
+func Test_SchedulerCancel(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.TODO(), nil, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cf := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cf()
+
+	if err := s.acquire(ctx, nil, 1, 0); err == nil {
+		t.Fatalf("acquire(), expected error, when ctx is done")
+	}
+
+	s.release(nil, 1)
+	if err := s.acquire(context.TODO(), nil, 1, 0); err != nil {
+		t.Fatalf("acquire(), unexpected error = %v, after a cancelled waiter", err)
+	}
+}
+
+func Test_SchedulerFullPool(t *testing.T) {
+	s := newScheduler(2)
+	pool := s.newPool(1)
+	if err := s.acquire(context.TODO(), pool, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cf := context.WithTimeout(context.TODO(), time.Second)
+	defer cf()
+
+	// INFO: waiter of a full pool, even with a higher priority, must not hold back others
+	go s.acquire(ctx, pool, 1, 10)
+	for {
+		s.mu.Lock()
+		n := len(s.waiters)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.acquire(ctx, nil, 1, 0); err != nil {
+		t.Fatalf("acquire(), unexpected error = %v, while only a pool is full", err)
+	}
+}
@@ -0,0 +1,180 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	ssePhaseStarted   = "started"
+	ssePhaseSucceeded = "succeeded"
+	ssePhaseFailed    = "failed"
+)
+
+// sseEvent is sent to SSE clients, whenever a watched task starts, or finishes
+type sseEvent struct {
+	Task         string    `json:"task"`
+	Phase        string    `json:"phase"`
+	Time         time.Time `json:"time"`
+	ChangedFiles []string  `json:"changedFiles,omitempty"`
+
+	// DurationMs, and ExitCode are only set, once task finishes
+	DurationMs int64 `json:"durationMs,omitempty"`
+	ExitCode   *int  `json:"exitCode,omitempty"`
+}
+
+// liveReloadJS reloads the page, it is included in, once a watched task succeeds.
+// With `?task=<name>` on its src, only that task's success reloads it
+const liveReloadJS = `(function () {
+  var src = new URL(document.currentScript.src);
+  var task = src.searchParams.get("task");
+  var events = new EventSource(src.origin + "/events");
+  events.onmessage = function (msg) {
+    var ev = JSON.parse(msg.data);
+    if (ev.phase === "succeeded" && (!task || ev.task === task)) {
+      location.reload();
+    }
+  };
+})();
+`
+
+// sseServer serves `/livereload.js`, and streams events of watched tasks, at `/events`, or any other path
+type sseServer struct {
+	addr string
+	mux  *http.ServeMux
+	srv  *http.Server
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+
+	// refs is the number of watched tasks, sharing this server
+	refs int
+}
+
+func newSSEServer(addr string) *sseServer {
+	s := &sseServer{addr: addr, mux: http.NewServeMux(), clients: make(map[chan []byte]struct{})}
+	s.mux.HandleFunc("/livereload.js", s.serveLiveReload)
+	s.mux.HandleFunc("/", s.serveEvents)
+	return s
+}
+
+func (s *sseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *sseServer) serveLiveReload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript")
+	fmt.Fprint(w, liveReloadJS)
+}
+
+func (s *sseServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// INFO: client is registered before headers are sent, so that it gets every event, once it is connected
+	ch := make(chan []byte, 16)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// publish sends ev to all connected clients. Clients that are too slow to keep up, miss it
+func (s *sseServer) publish(ev sseEvent) {
+	if s == nil {
+		return
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+// sseServers are shared by watched tasks, by address
+var sseServers = struct {
+	sync.Mutex
+	m map[string]*sseServer
+}{m: make(map[string]*sseServer)}
+
+// acquireSSEServer returns the SSE server listening at addr, starting it if needed.
+// Every acquired server must be released
+func acquireSSEServer(addr string) (*sseServer, error) {
+	sseServers.Lock()
+	defer sseServers.Unlock()
+
+	if s, ok := sseServers.m[addr]; ok {
+		s.refs++
+		return s, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSSEServer(addr)
+	s.srv = &http.Server{Handler: s}
+	s.refs = 1
+	go s.srv.Serve(l)
+
+	sseServers.m[addr] = s
+	return s, nil
+}
+
+// release stops the server, once no watched task uses it
+func (s *sseServer) release() {
+	if s == nil {
+		return
+	}
+
+	sseServers.Lock()
+	defer sseServers.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+
+	delete(sseServers.m, s.addr)
+	// INFO: event streams never end on their own, so the server is closed, instead of being shut down gracefully
+	s.srv.Close()
+}
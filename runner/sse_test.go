@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+)
+
+func Test_SSEServer(t *testing.T) {
+	s := newSSEServer("")
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	t.Run("1. serves livereload.js", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/livereload.js")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+		if ct := resp.Header.Get("Content-Type"); ct != "application/javascript" {
+			t.Errorf("content type, got = %q, want = %q", ct, "application/javascript")
+		}
+		if !strings.Contains(string(b), "EventSource") {
+			t.Errorf("livereload.js does not subscribe to events, got = %s", b)
+		}
+	})
+
+	t.Run("2. streams events of watched tasks", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		wl := &watchLoop{
+			logger: log.New(),
+			task: &watchTarget{name: "web", executor: newCmdExecutor(context.TODO(), cmdExecutorArgs{
+				Logger:   log.New(),
+				Commands: []CommandGroup{shellTask("web", "exit 2")},
+				Stdout:   new(bytes.Buffer),
+				Stderr:   new(bytes.Buffer),
+			})},
+			sse:        s,
+			debounce:   10 * time.Millisecond,
+			runOnStart: true,
+		}
+
+		go wl.run(ctx, nil, nil, nil)
+
+		var got []sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for len(got) < 2 && scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+
+			var ev sseEvent
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, ev)
+		}
+
+		if len(got) != 2 {
+			t.Fatalf("events, got = %+v, want 2 of them", got)
+		}
+
+		if got[0].Task != "web" || got[0].Phase != ssePhaseStarted || got[0].ExitCode != nil {
+			t.Errorf("first event, got = %+v, want task (web) to have started", got[0])
+		}
+
+		if got[1].Phase != ssePhaseFailed || got[1].ExitCode == nil || *got[1].ExitCode != 2 {
+			t.Errorf("second event, got = %+v, want task (web) to have failed with exit code 2", got[1])
+		}
+	})
+}
+
+func Test_AcquireSSEServer(t *testing.T) {
+	a, err := acquireSSEServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := acquireSSEServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a != b {
+		t.Errorf("acquireSSEServer(), tasks with the same addr, must share one server")
+	}
+
+	a.release()
+	if _, ok := sseServers.m["127.0.0.1:0"]; !ok {
+		t.Errorf("release(), server must keep running, while a task still uses it")
+	}
+
+	b.release()
+	if _, ok := sseServers.m["127.0.0.1:0"]; ok {
+		t.Errorf("release(), server must stop, once no task uses it")
+	}
+}
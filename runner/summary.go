@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nxtcoder17/runfile/types"
+)
+
+// taskNode is a single execution of a task, along with its commands, and the tasks it ran via `run`
+type taskNode struct {
+	ID        int
+	Name      string
+	Status    string
+	ExitCode  int
+	StartedAt time.Time
+	Duration  time.Duration
+
+	// Ignored is true, when the task failed, but was allowed to, i.e. `ignoreError: true`
+	Ignored bool
+
+	Commands []*commandNode
+	Tasks    []*taskNode
+}
+
+type commandNode struct {
+	ID        int
+	TaskName  string
+	Command   string
+	Status    string
+	ExitCode  int
+	StartedAt time.Time
+	Duration  time.Duration
+	Ignored   bool
+	Stderr    string
+}
+
+// taskTree builds tree of tasks from events. Tasks, and commands are ordered by when they started,
+// and the ones that never finished have status TaskStatusRunning
+func taskTree(events []Event) []*taskNode {
+	var roots []*taskNode
+	tasks := make(map[int]*taskNode)
+	commands := make(map[int]*commandNode)
+
+	for _, ev := range events {
+		switch ev.Kind {
+		case EventTaskStarted:
+			t := &taskNode{ID: ev.TaskID, Name: ev.TaskName, Status: TaskStatusRunning, StartedAt: ev.Time}
+			tasks[ev.TaskID] = t
+			if parent, ok := tasks[ev.ParentTaskID]; ok {
+				parent.Tasks = append(parent.Tasks, t)
+				continue
+			}
+			roots = append(roots, t)
+
+		case EventTaskFinished:
+			if t, ok := tasks[ev.TaskID]; ok {
+				t.Status, t.ExitCode, t.Ignored = ev.Status, ev.ExitCode, ev.Ignored
+				t.Duration = ev.Time.Sub(t.StartedAt)
+			}
+
+		case EventCommandStarted:
+			c := &commandNode{ID: ev.CommandID, TaskName: ev.TaskName, Command: ev.Command, Status: TaskStatusRunning, StartedAt: ev.Time}
+			commands[ev.CommandID] = c
+			if t, ok := tasks[ev.TaskID]; ok {
+				t.Commands = append(t.Commands, c)
+			}
+
+		case EventCommandFinished:
+			if c, ok := commands[ev.CommandID]; ok {
+				c.Status, c.ExitCode, c.Ignored, c.Stderr = ev.Status, ev.ExitCode, ev.Ignored, ev.Stderr
+				c.Duration = ev.Time.Sub(c.StartedAt)
+			}
+		}
+	}
+
+	return roots
+}
+
+// walkTasks calls fn for every task of the tree, parents before their children
+func walkTasks(tasks []*taskNode, depth int, fn func(t *taskNode, depth int)) {
+	for _, t := range tasks {
+		fn(t, depth)
+		walkTasks(t.Tasks, depth+1, fn)
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(10 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// firstLine returns the first line of a command, marking that it had more
+func firstLine(cmd string) string {
+	cmd = strings.TrimSpace(cmd)
+	if idx := strings.IndexByte(cmd, '\n'); idx != -1 {
+		return cmd[:idx] + " ..."
+	}
+	return cmd
+}
+
+// printSummary writes status, exit code and duration of every task, when more than one task ran,
+// or any of them did not succeed. It then writes every failed command, along with its exit code
+func (r *runReport) printSummary(w io.Writer) {
+	roots := taskTree(r.list())
+
+	total := 0
+	counts := make(map[string]int)
+	nameWidth, statusWidth := 0, len(TaskStatusCancelled)
+	var failures []*commandNode
+
+	walkTasks(roots, 0, func(t *taskNode, depth int) {
+		total++
+		counts[t.Status]++
+		nameWidth = max(nameWidth, 2*depth+len(t.Name))
+		for _, c := range t.Commands {
+			if c.Status == TaskStatusFailure {
+				failures = append(failures, c)
+			}
+		}
+	})
+
+	if total > 1 || counts[TaskStatusSuccess] != total {
+		parts := []string{fmt.Sprintf("%d task(s)", total)}
+		for _, s := range []struct{ status, label string }{
+			{TaskStatusSuccess, "succeeded"},
+			{TaskStatusFailure, "failed"},
+			{TaskStatusCancelled, "cancelled"},
+			{TaskStatusRunning, "never finished"},
+		} {
+			if counts[s.status] > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", counts[s.status], s.label))
+			}
+		}
+
+		prefix := types.GetStyledPrefix("summary")
+		if counts[TaskStatusSuccess] != total {
+			prefix = types.GetErrorStyledPrefix("summary")
+		}
+
+		fmt.Fprintf(w, "%s%s\n", prefix, strings.Join(parts, ", "))
+		walkTasks(roots, 0, func(t *taskNode, depth int) {
+			suffix := ""
+			if t.Ignored {
+				suffix = " (ignored)"
+			}
+			name := strings.Repeat("  ", depth) + t.Name
+			fmt.Fprintf(w, "  %-*s  %-*s  exit code %-3d  %s%s\n", nameWidth, name, statusWidth, t.Status, t.ExitCode, formatDuration(t.Duration), suffix)
+		})
+	}
+
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%s%d command(s) failed\n", types.GetErrorStyledPrefix("summary"), len(failures))
+	for _, f := range failures {
+		suffix := ""
+		if f.Ignored {
+			suffix = " (ignored)"
+		}
+
+		fmt.Fprintf(w, "  - %s: exit code %d, %q%s\n", f.TaskName, f.ExitCode, firstLine(f.Command), suffix)
+	}
+}
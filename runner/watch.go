@@ -0,0 +1,685 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/fsnotify/fsnotify"
+	"github.com/nxtcoder17/go.pkgs/log"
+	"github.com/nxtcoder17/runfile/errors"
+	fn "github.com/nxtcoder17/runfile/functions"
+	"github.com/nxtcoder17/runfile/parser"
+	"github.com/nxtcoder17/runfile/types"
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// resolveWatch returns watch settings of a task, when watching is enabled, either by its `watch` block, or by `run -w`.
+// Task's working dir, and its Runfile's dir are watched, only when neither `dirs`, nor `--watch-path` are set
+func resolveWatch(pt *types.ParsedTask, runfilePath string, args runTaskArgs) *types.TaskWatch {
+	var w types.TaskWatch
+	if pt.Watch != nil {
+		w = *pt.Watch
+	}
+
+	if !args.watch && (pt.Watch == nil || !fn.DefaultIfNil(pt.Watch.Enable, true)) {
+		return nil
+	}
+
+	dirs := append([]string{}, w.Dirs...)
+	for _, p := range args.watchPaths {
+		dirs = append(dirs, fn.Must(filepath.Abs(p)))
+	}
+
+	// INFO: otherwise, a task writing into its working dir, e.g. logs, or build output, would keep restarting itself
+	if len(dirs) == 0 {
+		dirs = []string{pt.WorkingDir, filepath.Dir(runfilePath)}
+	}
+
+	w.Dirs = nil
+	for _, d := range dirs {
+		if !slices.Contains(w.Dirs, d) {
+			w.Dirs = append(w.Dirs, d)
+		}
+	}
+
+	for _, p := range args.watchIgnore {
+		w.IgnoreDirs = append(w.IgnoreDirs, fn.Must(filepath.Abs(p)))
+	}
+
+	if len(args.watchExtensions) > 0 {
+		w.Extensions = nil
+		for _, ext := range args.watchExtensions {
+			w.Extensions = append(w.Extensions, "."+strings.TrimPrefix(ext, "."))
+		}
+	}
+
+	return &w
+}
+
+// WatchExplanation tells whether a change to a file, reruns a watched task, and why.
+// Routes are the tasks, file is routed to by `watch.on`, which run instead of the watched task
+type WatchExplanation struct {
+	Task   string
+	Rerun  bool
+	Reason string
+	Routes []string
+}
+
+// ExplainWatch reports, for each of tasks, whether a change to file would rerun it, as if it were run with `run -w`.
+// When no tasks are given, every task with an enabled `watch` block is explained
+func ExplainWatch(ctx types.Context, prf *types.ParsedRunfile, tasks []string, file string) ([]WatchExplanation, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	named := len(tasks) > 0
+	if !named {
+		for name, task := range prf.Tasks {
+			if task.Watch != nil && fn.DefaultIfNil(task.Watch.Enable, true) {
+				tasks = append(tasks, name)
+			}
+		}
+		slices.Sort(tasks)
+	}
+
+	result := make([]WatchExplanation, 0, len(tasks))
+	for _, name := range tasks {
+		task, ok := prf.Tasks[name]
+		if !ok {
+			return nil, errors.ErrTaskNotFound.KV("task", name)
+		}
+
+		pt, err := parser.ParseTask(ctx, prf, task)
+		if err != nil {
+			return nil, errors.WithErr(err)
+		}
+
+		watch := resolveWatch(pt, *task.Metadata.RunfilePath, runTaskArgs{watch: named})
+		wf := newWatchFilter(pt.WorkingDir, watch)
+		rerun, reason := wf.explain(file)
+
+		var routes []string
+		if rerun {
+			routes = wf.routes(file)
+		}
+		result = append(result, WatchExplanation{Task: name, Rerun: rerun, Reason: reason, Routes: routes})
+	}
+
+	return result, nil
+}
+
+const DefaultWatchDebounce = 100 * time.Millisecond
+
+// defaultIgnoredDirs are never watched. `.runfile` holds task logs, which would otherwise retrigger runs
+var defaultIgnoredDirs = []string{".git", "node_modules", ".runfile"}
+
+// isEditorTempFile reports whether file is a swap, or backup file, that editors write alongside the edited one
+func isEditorTempFile(name string) bool {
+	return strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".swp") || strings.HasSuffix(name, ".swx") || name == "4913"
+}
+
+// watchFilter decides which changed files rerun a task
+type watchFilter struct {
+	// workingDir is the dir, include, and exclude globs are relative to
+	workingDir string
+	watch      *types.TaskWatch
+
+	// gitignores caches parsed `.gitignore` of dirs, nil when a dir has none
+	gitignores map[string]*gitignore.GitIgnore
+}
+
+func newWatchFilter(workingDir string, watch *types.TaskWatch) *watchFilter {
+	return &watchFilter{workingDir: workingDir, watch: watch, gitignores: make(map[string]*gitignore.GitIgnore)}
+}
+
+// matchGlob returns the first pattern that matches file. Patterns without a `/` are matched against file name
+func (wf *watchFilter) matchGlob(patterns []string, file string) (string, bool) {
+	rel, err := filepath.Rel(wf.workingDir, file)
+	if err != nil {
+		rel = file
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// routes returns names of the tasks, that a change to file runs, as per `watch.on`
+func (wf *watchFilter) routes(file string) []string {
+	var names []string
+	for pattern, name := range wf.watch.On {
+		if _, ok := wf.matchGlob([]string{pattern}, file); ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// root returns the outermost watched dir, that contains file
+func (wf *watchFilter) root(file string) (string, bool) {
+	root := ""
+	for _, dir := range wf.watch.Dirs {
+		if file != dir && !strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			continue
+		}
+		if root == "" || len(dir) < len(root) {
+			root = dir
+		}
+	}
+	return root, root != ""
+}
+
+// gitignored reports which `.gitignore`, between file's watched root, and its dir, ignores file
+func (wf *watchFilter) gitignored(file string, isDir bool) (string, bool) {
+	root, ok := wf.root(file)
+	if !ok || file == root {
+		return "", false
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	for _, dir := range slices.Backward(dirs) {
+		gi, ok := wf.gitignores[dir]
+		if !ok {
+			gi, _ = gitignore.CompileIgnoreFile(filepath.Join(dir, ".gitignore"))
+			wf.gitignores[dir] = gi
+		}
+		if gi == nil {
+			continue
+		}
+
+		rel := fn.Must(filepath.Rel(dir, file))
+		if isDir {
+			rel += "/"
+		}
+		if matched, how := gi.MatchesPathHow(rel); matched {
+			return fmt.Sprintf("%s:%d (%s)", filepath.Join(dir, ".gitignore"), how.LineNo, how.Line), true
+		}
+	}
+	return "", false
+}
+
+func (wf *watchFilter) isIgnoredDir(dir string) bool {
+	if slices.Contains(defaultIgnoredDirs, filepath.Base(dir)) {
+		return true
+	}
+
+	for _, ignored := range wf.watch.IgnoreDirs {
+		if dir == ignored || strings.HasPrefix(dir, ignored+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	if wf.watch.Gitignore {
+		if _, ok := wf.gitignored(dir, true); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// explain reports whether a change to file should rerun the task, and why
+func (wf *watchFilter) explain(file string) (bool, string) {
+	if isEditorTempFile(filepath.Base(file)) {
+		return false, "editor's temporary file"
+	}
+
+	if _, ok := wf.root(file); !ok {
+		return false, "outside of watched dirs"
+	}
+
+	for dir := filepath.Dir(file); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if _, ok := wf.root(dir); !ok {
+			break
+		}
+		if wf.isIgnoredDir(dir) {
+			return false, fmt.Sprintf("inside ignored dir (%s)", dir)
+		}
+	}
+
+	if pattern, ok := wf.matchGlob(wf.watch.Exclude, file); ok {
+		return false, fmt.Sprintf("excluded by (%s)", pattern)
+	}
+
+	if wf.watch.Gitignore {
+		if by, ok := wf.gitignored(file, false); ok {
+			return false, fmt.Sprintf("ignored by %s", by)
+		}
+	}
+
+	ext := filepath.Ext(file)
+	if slices.Contains(wf.watch.IgnoreExtensions, ext) {
+		return false, fmt.Sprintf("extension (%s) is ignored", ext)
+	}
+
+	if len(wf.watch.Extensions) > 0 && !slices.Contains(wf.watch.Extensions, ext) {
+		return false, fmt.Sprintf("extension (%s) is not one of %v", ext, wf.watch.Extensions)
+	}
+
+	if len(wf.watch.Include) > 0 {
+		pattern, ok := wf.matchGlob(wf.watch.Include, file)
+		if !ok {
+			return false, fmt.Sprintf("matches none of included %v", wf.watch.Include)
+		}
+		return true, fmt.Sprintf("included by (%s)", pattern)
+	}
+
+	if len(wf.watch.Extensions) > 0 {
+		return true, fmt.Sprintf("extension (%s) is watched", ext)
+	}
+	return true, "inside watched dirs"
+}
+
+// fileWatcher watches dirs of a task recursively, and reports files that changed
+type fileWatcher struct {
+	*watchFilter
+	logger  log.Logger
+	watcher *fsnotify.Watcher
+
+	// config are files, task is parsed from, i.e. Runfile, its includes, and dotenv files.
+	// Their changes are reported separately, irrespective of filters
+	mu     sync.Mutex
+	config []string
+}
+
+func newFileWatcher(logger log.Logger, filter *watchFilter) (*fileWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := &fileWatcher{watchFilter: filter, logger: logger, watcher: w}
+	for _, dir := range filter.watch.Dirs {
+		if err := fw.addRecursive(dir); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return fw, nil
+}
+
+func (fw *fileWatcher) addRecursive(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// INFO: dirs may be removed, while they are being walked
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if fw.isIgnoredDir(path) {
+			return filepath.SkipDir
+		}
+
+		return fw.watcher.Add(path)
+	})
+}
+
+// watchConfig watches files, task is parsed from, in place of the ones watched before
+func (fw *fileWatcher) watchConfig(files []string) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.config = files
+	for _, file := range files {
+		// INFO: dirs are watched, as editors often save files, by replacing them
+		if err := fw.watcher.Add(filepath.Dir(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fw *fileWatcher) isConfig(file string) bool {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return slices.Contains(fw.config, file)
+}
+
+// run sends every changed file that matches, on changes, and every changed config file on reloads, until ctx is done
+func (fw *fileWatcher) run(ctx context.Context, changes chan<- string, reloads chan<- string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			fw.logger.Warn("while watching files", "err", err)
+		case ev, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+
+			// INFO: fsnotify is not recursive, so newly created dirs must be watched as well
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err := fw.addRecursive(ev.Name); err != nil {
+						fw.logger.Warn("failed to watch new dir", "dir", ev.Name, "err", err)
+					}
+					continue
+				}
+			}
+
+			if fw.isConfig(ev.Name) {
+				fw.logger.Debug("config file changed", "file", ev.Name, "op", ev.Op.String())
+				select {
+				case reloads <- ev.Name:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
+			// INFO: edited `.gitignore` is parsed again, on next lookup
+			if filepath.Base(ev.Name) == ".gitignore" {
+				delete(fw.gitignores, filepath.Dir(ev.Name))
+			}
+
+			if ok, reason := fw.explain(ev.Name); !ok {
+				fw.logger.Debug("skipping change", "file", ev.Name, "reason", reason)
+				continue
+			}
+
+			fw.logger.Debug("file changed", "file", ev.Name, "op", ev.Op.String())
+			select {
+			case changes <- ev.Name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (fw *fileWatcher) Close() error {
+	return fw.watcher.Close()
+}
+
+// watchTarget is a task, that watch loop runs
+type watchTarget struct {
+	name     string
+	executor *cmdExecutor
+
+	// commands, when set, rebuilds command groups of the task, before every run, with files that triggered it
+	commands func(changedFiles []string) ([]CommandGroup, error)
+
+	running   bool
+	startedAt time.Time
+	// runs is incremented every time target is started, or stopped, so that results of stopped runs are ignored
+	runs int
+	// queued are files, that changed while target was running, with queue policy
+	queued []string
+}
+
+type watchResult struct {
+	target *watchTarget
+	run    int
+	err    error
+}
+
+// watchLoop reruns watched task whenever files change. Changes are batched until they have been quiet for
+// debounce, and changes that arrive while a target is running, are handled as per policy
+type watchLoop struct {
+	logger log.Logger
+
+	// task is the watched task, and routes are the tasks, its changed files are routed to, by name
+	task   *watchTarget
+	routes map[string]*watchTarget
+
+	// route returns names of the tasks, a changed file runs, when it runs none of them, task is rerun
+	route func(file string) []string
+
+	// reload parses the task again, once its Runfile, includes, or dotenv files change. When its watch settings
+	// changed too, it returns the loop to continue with, whose targets, routes, sse, debounce, and policy replace these
+	reload func() (*watchLoop, error)
+
+	// sse, when set, gets events of targets, as they start, and finish
+	sse *sseServer
+
+	debounce   time.Duration
+	runOnStart bool
+	policy     types.WatchPolicy
+}
+
+// targets groups batch of changed files, by the target they run
+func (wl *watchLoop) targets(batch []string) ([]*watchTarget, map[*watchTarget][]string) {
+	var targets []*watchTarget
+	files := make(map[*watchTarget][]string)
+
+	add := func(t *watchTarget, file string) {
+		if _, ok := files[t]; !ok {
+			targets = append(targets, t)
+		}
+		files[t] = append(files[t], file)
+	}
+
+	for _, file := range batch {
+		var names []string
+		if wl.route != nil {
+			names = wl.route(file)
+		}
+
+		if len(names) == 0 {
+			add(wl.task, file)
+			continue
+		}
+
+		for _, name := range names {
+			add(wl.routes[name], file)
+		}
+	}
+
+	return targets, files
+}
+
+// run runs targets as files change, and config files get reloaded, until ctx is done, or a quit action is received.
+// It returns ctx's error, when ctx is done, and nil on quit
+func (wl *watchLoop) run(ctx context.Context, changes <-chan string, reloads <-chan string, actions <-chan watchAction) error {
+	finished := make(chan watchResult)
+
+	start := func(t *watchTarget, changedFiles []string) {
+		if t.commands != nil {
+			groups, err := t.commands(changedFiles)
+			if err != nil {
+				wl.logger.Warn("failed to prepare task, waiting for changes", "task", t.name, "err", err)
+				return
+			}
+			t.executor.setCommands(groups)
+		}
+
+		t.running = true
+		t.runs++
+		t.startedAt = time.Now()
+		wl.sse.publish(sseEvent{Task: t.name, Phase: ssePhaseStarted, Time: t.startedAt, ChangedFiles: changedFiles})
+
+		run := t.runs
+		go func() {
+			err := t.executor.Start()
+			select {
+			case finished <- watchResult{target: t, run: run, err: err}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	stop := func(t *watchTarget) {
+		if !t.running {
+			return
+		}
+		t.executor.Stop()
+		t.running = false
+		t.runs++
+	}
+
+	if wl.runOnStart {
+		start(wl.task, nil)
+	}
+
+	var batch []string
+	var reload bool
+
+	// paused drops changes, until watching is resumed
+	var paused bool
+
+	timer := time.NewTimer(wl.debounce)
+	timer.Stop()
+
+	stopAll := func() {
+		stop(wl.task)
+		for _, t := range wl.routes {
+			stop(t)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			stopAll()
+			// INFO: so that interrupted watch exits with 130, as any other interrupted run. Only quit action exits cleanly
+			return ctx.Err()
+
+		case action := <-actions:
+			switch action {
+			case watchActionRestart:
+				stop(wl.task)
+				start(wl.task, nil)
+			case watchActionPause:
+				paused, batch, reload = true, nil, false
+				timer.Stop()
+			case watchActionResume:
+				paused = false
+			case watchActionQuit:
+				stopAll()
+				return nil
+			}
+
+		case file := <-changes:
+			if paused {
+				continue
+			}
+			if !slices.Contains(batch, file) {
+				batch = append(batch, file)
+			}
+			timer.Reset(wl.debounce)
+
+		case file := <-reloads:
+			if paused {
+				continue
+			}
+			wl.logger.Debug("config file changed", "file", file)
+			reload = true
+			timer.Reset(wl.debounce)
+
+		case <-timer.C:
+			var restart bool
+			if reload {
+				reload = false
+				// INFO: when reload fails, previous run is left as is
+				next, err := wl.reload()
+				switch {
+				case err != nil:
+					errors.WithErr(err).Log()
+					wl.logger.Warn("failed to reload, previous run is left running")
+				case next != nil:
+					wl.logger.Info("reloaded with new watch settings, restarting")
+					stopAll()
+					wl.task, wl.routes, wl.route, wl.sse = next.task, next.routes, next.route, next.sse
+					wl.debounce, wl.policy = next.debounce, next.policy
+					restart = true
+				default:
+					wl.logger.Info("reloaded, restarting")
+					stop(wl.task)
+					restart = true
+				}
+			}
+
+			// INFO: batch is routed after reload, as per the routes it applied
+			wl.logger.Debug("files changed", "files", batch)
+			targets, files := wl.targets(batch)
+			batch = nil
+
+			if restart {
+				start(wl.task, files[wl.task])
+				delete(files, wl.task)
+			}
+
+			for _, t := range targets {
+				if _, ok := files[t]; !ok {
+					continue
+				}
+
+				switch {
+				case !t.running:
+					start(t, files[t])
+				case wl.policy == types.WatchPolicyQueue:
+					for _, f := range files[t] {
+						if !slices.Contains(t.queued, f) {
+							t.queued = append(t.queued, f)
+						}
+					}
+				case wl.policy == types.WatchPolicyIgnore:
+					wl.logger.Debug("task is running, ignoring changes", "task", t.name)
+				default:
+					stop(t)
+					start(t, files[t])
+				}
+			}
+
+		case r := <-finished:
+			// INFO: result of a run, that was stopped to be restarted
+			if r.run != r.target.runs {
+				continue
+			}
+
+			t := r.target
+			t.running = false
+
+			ev := sseEvent{Task: t.name, Phase: ssePhaseSucceeded, Time: time.Now(), DurationMs: time.Since(t.startedAt).Milliseconds()}
+			if r.err != nil {
+				wl.logger.Debug("run failed, waiting for changes", "task", t.name, "err", r.err)
+				ev.Phase = ssePhaseFailed
+			}
+			exitCode := errors.ExitCode(r.err)
+			ev.ExitCode = &exitCode
+			wl.sse.publish(ev)
+
+			if len(t.queued) > 0 {
+				queued := t.queued
+				t.queued = nil
+				start(t, queued)
+			}
+		}
+	}
+}
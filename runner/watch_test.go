@@ -0,0 +1,474 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nxtcoder17/go.pkgs/log"
+	fn "github.com/nxtcoder17/runfile/functions"
+	"github.com/nxtcoder17/runfile/types"
+)
+
+func Test_ResolveWatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		watch *types.TaskWatch
+		args  runTaskArgs
+		want  *types.TaskWatch
+	}{
+		{
+			name: "1. without watch block, or -w, task is not watched",
+			want: nil,
+		},
+		{
+			name: "2. -w watches working dir, and Runfile's dir",
+			args: runTaskArgs{watch: true},
+			want: &types.TaskWatch{Dirs: []string{"/app/web", "/app"}},
+		},
+		{
+			name:  "3. disabled watch block, is enabled by -w",
+			watch: &types.TaskWatch{Enable: fn.New(false), Extensions: []string{".go"}},
+			args:  runTaskArgs{watch: true},
+			want:  &types.TaskWatch{Enable: fn.New(false), Dirs: []string{"/app/web", "/app"}, Extensions: []string{".go"}},
+		},
+		{
+			name:  "4. flags extend dirs, and ignored dirs, and override extensions",
+			watch: &types.TaskWatch{Dirs: []string{"/app/web/src"}, IgnoreDirs: []string{"/app/web/dist"}, Extensions: []string{".go"}},
+			args:  runTaskArgs{watchPaths: []string{"/lib", "/app"}, watchIgnore: []string{"/app/web/tmp"}, watchExtensions: []string{"ts", ".tsx"}},
+			want: &types.TaskWatch{
+				Dirs:       []string{"/app/web/src", "/lib", "/app"},
+				IgnoreDirs: []string{"/app/web/dist", "/app/web/tmp"},
+				Extensions: []string{".ts", ".tsx"},
+			},
+		},
+		{
+			name: "5. --watch-path replaces working dir, and Runfile's dir",
+			args: runTaskArgs{watch: true, watchPaths: []string{"/app/web/src"}},
+			want: &types.TaskWatch{Dirs: []string{"/app/web/src"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := &types.ParsedTask{WorkingDir: "/app/web", Watch: tt.watch}
+			got := resolveWatch(pt, "/app/Runfile.yml", tt.args)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveWatch(), got = %+v, want = %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_WatchFilter(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("dist/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", ".gitignore"), []byte("*.tmp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	watch := &types.TaskWatch{
+		Dirs:      []string{root},
+		Include:   []string{"**/*.go", "Runfile.yml"},
+		Exclude:   []string{"*_test.go", "gen/**"},
+		Gitignore: true,
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{name: "1. included by glob", file: "pkg/main.go", want: true},
+		{name: "2. pattern without /, matches file name in any dir", file: "pkg/main_test.go", want: false},
+		{name: "3. excluded by glob", file: "gen/api.go", want: false},
+		{name: "4. ignored by .gitignore of watched dir", file: "dist/main.go", want: false},
+		{name: "5. ignored by nested .gitignore", file: "pkg/cache.tmp", want: false},
+		{name: "6. nested .gitignore does not apply to parent", file: "Runfile.yml", want: true},
+		{name: "7. not included", file: "README.md", want: false},
+		{name: "8. outside of watched dirs", file: "../elsewhere/main.go", want: false},
+		{name: "9. inside ignored dirs", file: "node_modules/pkg/index.go", want: false},
+	}
+
+	wf := newWatchFilter(root, watch)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := wf.explain(filepath.Join(root, tt.file))
+			if got != tt.want {
+				t.Errorf("explain(%s), got = %v (%s), want = %v", tt.file, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func Test_WatchLoopPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy types.WatchPolicy
+		want   []string
+	}{
+		{
+			name:   "1. restart, stops current run, and starts a new one",
+			policy: types.WatchPolicyRestart,
+			want:   []string{TaskStatusCancelled, TaskStatusSuccess},
+		},
+		{
+			name:   "2. queue, lets current run finish, before starting a new one",
+			policy: types.WatchPolicyQueue,
+			want:   []string{TaskStatusSuccess, TaskStatusSuccess},
+		},
+		{
+			name:   "3. ignore, drops changes while running",
+			policy: types.WatchPolicyIgnore,
+			want:   []string{TaskStatusSuccess},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &runReport{}
+			wl := &watchLoop{
+				logger: log.New(),
+				task: &watchTarget{name: "test", executor: newCmdExecutor(context.TODO(), cmdExecutorArgs{
+					Logger:   log.New(),
+					Commands: []CommandGroup{shellTask("test", "sleep 0.3")},
+					Stdout:   new(bytes.Buffer),
+					Stderr:   new(bytes.Buffer),
+					Report:   report,
+				})},
+				debounce:   10 * time.Millisecond,
+				runOnStart: true,
+				policy:     tt.policy,
+			}
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 1200*time.Millisecond)
+			defer cancel()
+
+			changes := make(chan string)
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				changes <- "main.go"
+			}()
+
+			if err := wl.run(ctx, changes, nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, task := range taskTree(report.list()) {
+				got = append(got, task.Status)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statuses of runs, got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_WatchLoopRoutes(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string][][]string)
+
+	target := func(name string) *watchTarget {
+		return &watchTarget{
+			name:     name,
+			executor: newCmdExecutor(context.TODO(), cmdExecutorArgs{Logger: log.New(), Stdout: new(bytes.Buffer), Stderr: new(bytes.Buffer)}),
+			commands: func(changedFiles []string) ([]CommandGroup, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				got[name] = append(got[name], changedFiles)
+				return []CommandGroup{shellTask(name, "true")}, nil
+			},
+		}
+	}
+
+	wl := &watchLoop{
+		logger: log.New(),
+		task:   target("dev"),
+		routes: map[string]*watchTarget{"css": target("css"), "lint": target("lint")},
+		route: func(file string) []string {
+			switch filepath.Ext(file) {
+			case ".css":
+				return []string{"css", "lint"}
+			case ".go":
+				return []string{"lint"}
+			}
+			return nil
+		},
+		debounce:   10 * time.Millisecond,
+		runOnStart: true,
+		policy:     types.WatchPolicyQueue,
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 500*time.Millisecond)
+	defer cancel()
+
+	changes := make(chan string)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		for _, f := range []string{"a.css", "b.go", "README.md", "a.css"} {
+			changes <- f
+		}
+	}()
+
+	if err := wl.run(ctx, changes, nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+
+	want := map[string][][]string{
+		"dev":  {nil, {"README.md"}},
+		"css":  {{"a.css"}},
+		"lint": {{"a.css", "b.go"}},
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed files of runs, got = %v, want = %v", got, want)
+	}
+}
+
+func Test_WatchLoopReload(t *testing.T) {
+	report := &runReport{}
+	reloadErr := fmt.Errorf("invalid runfile")
+
+	var mu sync.Mutex
+	var reloaded int
+
+	wl := &watchLoop{
+		logger: log.New(),
+		task: &watchTarget{name: "dev", executor: newCmdExecutor(context.TODO(), cmdExecutorArgs{
+			Logger:   log.New(),
+			Commands: []CommandGroup{shellTask("dev", "sleep 0.5")},
+			Stdout:   new(bytes.Buffer),
+			Stderr:   new(bytes.Buffer),
+			Report:   report,
+		})},
+		reload: func() (*watchLoop, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reloaded++
+			// INFO: first reload fails, and leaves the run as is, while the second one restarts it
+			if reloaded == 1 {
+				return nil, reloadErr
+			}
+			return nil, nil
+		},
+		debounce:   10 * time.Millisecond,
+		runOnStart: true,
+		policy:     types.WatchPolicyIgnore,
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 400*time.Millisecond)
+	defer cancel()
+
+	reloads := make(chan string)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		reloads <- "Runfile.yml"
+		time.Sleep(100 * time.Millisecond)
+		reloads <- "Runfile.yml"
+	}()
+
+	if err := wl.run(ctx, nil, reloads, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, task := range taskTree(report.list()) {
+		got = append(got, task.Status)
+	}
+
+	if want := []string{TaskStatusCancelled, TaskStatusCancelled}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses of runs, got = %v, want = %v", got, want)
+	}
+}
+
+func Test_WatchLoopReloadSettings(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string][][]string)
+
+	target := func(name string) *watchTarget {
+		return &watchTarget{
+			name:     name,
+			executor: newCmdExecutor(context.TODO(), cmdExecutorArgs{Logger: log.New(), Stdout: new(bytes.Buffer), Stderr: new(bytes.Buffer)}),
+			commands: func(changedFiles []string) ([]CommandGroup, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				got[name] = append(got[name], changedFiles)
+				return []CommandGroup{shellTask(name, "true")}, nil
+			},
+		}
+	}
+
+	wl := &watchLoop{
+		logger: log.New(),
+		task:   target("dev"),
+		// INFO: reload adds a route for css files, which applies to the changes batched along with it
+		reload: func() (*watchLoop, error) {
+			return &watchLoop{
+				task:   target("dev.reloaded"),
+				routes: map[string]*watchTarget{"css": target("css")},
+				route: func(file string) []string {
+					if filepath.Ext(file) == ".css" {
+						return []string{"css"}
+					}
+					return nil
+				},
+				debounce: 10 * time.Millisecond,
+				policy:   types.WatchPolicyQueue,
+			}, nil
+		},
+		debounce:   10 * time.Millisecond,
+		runOnStart: true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 400*time.Millisecond)
+	defer cancel()
+
+	changes, reloads := make(chan string), make(chan string)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		reloads <- "Runfile.yml"
+		changes <- "a.css"
+		changes <- "main.go"
+		time.Sleep(100 * time.Millisecond)
+		changes <- "b.css"
+	}()
+
+	if err := wl.run(ctx, changes, reloads, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+
+	want := map[string][][]string{
+		"dev":          {nil},
+		"dev.reloaded": {{"main.go"}},
+		"css":          {{"a.css"}, {"b.css"}},
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed files of runs, got = %v, want = %v", got, want)
+	}
+}
+
+func Test_WatchLoopActions(t *testing.T) {
+	report := &runReport{}
+	wl := &watchLoop{
+		logger: log.New(),
+		task: &watchTarget{name: "dev", executor: newCmdExecutor(context.TODO(), cmdExecutorArgs{
+			Logger:   log.New(),
+			Commands: []CommandGroup{shellTask("dev", "sleep 1")},
+			Stdout:   new(bytes.Buffer),
+			Stderr:   new(bytes.Buffer),
+			Report:   report,
+		})},
+		debounce:   10 * time.Millisecond,
+		runOnStart: true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Second)
+	defer cancel()
+
+	changes := make(chan string)
+	actions := make(chan watchAction)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		actions <- watchActionRestart
+		actions <- watchActionPause
+		// INFO: changes are dropped, while watching is paused
+		changes <- "main.go"
+		time.Sleep(100 * time.Millisecond)
+		actions <- watchActionResume
+		actions <- watchActionQuit
+	}()
+
+	startedAt := time.Now()
+	if err := wl.run(ctx, changes, nil, actions); err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(startedAt) > time.Second {
+		t.Errorf("run(), quit must stop watching, without waiting for ctx")
+	}
+
+	var got []string
+	for _, task := range taskTree(report.list()) {
+		got = append(got, task.Status)
+	}
+
+	if want := []string{TaskStatusCancelled, TaskStatusCancelled}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses of runs, got = %v, want = %v", got, want)
+	}
+}
+
+func Test_CreateCommandGroupsWatched(t *testing.T) {
+	tests := []struct {
+		name     string
+		template bool
+		cmd      string
+		want     string
+
+		// workingDir, and changedFiles, when set, replace a temp dir, and main.go in it
+		workingDir   string
+		changedFiles []string
+	}{
+		{
+			name: "1. braces, that are not meant as templates, pass through unchanged",
+			cmd:  "docker ps --format '{{.Foo}}'",
+			want: "docker ps --format '{{.Foo}}'",
+		},
+		{
+			name:     "2. with template: true, changed files are rendered into commands",
+			template: true,
+			cmd:      "gofmt -l {{ .ChangedFiles }}",
+			want:     "gofmt -l main.go",
+		},
+		{
+			name:         "3. changed files, that can not be made relative to working dir, are passed as they are",
+			template:     true,
+			cmd:          "gofmt -l {{ .ChangedFiles }}",
+			want:         "gofmt -l /src/main.go",
+			workingDir:   "web",
+			changedFiles: []string{"/src/main.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, changedFiles := tt.workingDir, tt.changedFiles
+			if dir == "" {
+				dir = t.TempDir()
+				changedFiles = []string{filepath.Join(dir, "main.go")}
+			}
+
+			groups, err := createCommandGroups(types.Context{Context: t.Context(), Logger: log.New()}, CreateCommandGroupArgs{
+				Runfile:      &types.ParsedRunfile{},
+				Task:         &types.ParsedTask{Name: "dev", WorkingDir: dir, Template: tt.template},
+				Watched:      true,
+				ChangedFiles: changedFiles,
+			}, []types.ParsedCommandJson{{Command: fn.New(tt.cmd)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := groups[0].Commands[0].Text; got != tt.want {
+				t.Errorf("createCommandGroups(), command\n\tgot = %q\n\twant = %q", got, tt.want)
+			}
+		})
+	}
+}
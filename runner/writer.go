@@ -2,44 +2,225 @@ package runner
 
 import (
 	"bytes"
-	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
+	"time"
 
+	"github.com/nxtcoder17/runfile/errors"
+	"github.com/nxtcoder17/runfile/terminal"
 	"github.com/nxtcoder17/runfile/types"
 )
 
 type PrefixedWriter struct {
+	mu     sync.Mutex
 	w      io.Writer
 	prefix []byte
 	buf    *bytes.Buffer
 	render func([]byte) []byte
+
+	// stamp, when set, returns timestamp, every line starts with
+	stamp func() string
+
+	// tty is set, when output comes from a pseudo terminal
+	tty bool
 }
 
+// Write writes every complete line of p with prefix. A trailing partial line is held back,
+// until either rest of it is written, or Flush is called
 func (pw *PrefixedWriter) Write(p []byte) (int, error) {
-	defer pw.buf.Reset()
-	n, err := pw.buf.Write(p)
-	if err != nil {
-		return n, err
-	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
 
+	pw.buf.Write(p)
 	for {
-		line, err := pw.buf.ReadBytes('\n')
-		if errors.Is(err, io.EOF) {
-			pw.buf.Reset()
-			pw.buf.Write(pw.render(line))
-			break
+		idx := bytes.IndexByte(pw.buf.Bytes(), '\n')
+		if idx == -1 {
+			return len(p), nil
 		}
 
-		if _, err := pw.w.Write(append(pw.prefix, pw.render(line)...)); err != nil {
-			return n, err
+		if err := pw.writeLine(pw.buf.Next(idx + 1)); err != nil {
+			return len(p), err
 		}
 	}
-	return n, nil
+}
+
+func (pw *PrefixedWriter) writeLine(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+
+	if pw.tty {
+		// INFO: pty ends lines with CRLF, and progress output redraws a line with carriage returns,
+		// which would overwrite the prefix, so only its final state is kept
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if idx := bytes.LastIndexByte(line, '\r'); idx != -1 {
+			line = line[idx+1:]
+		}
+	}
+
+	b := make([]byte, 0, len(pw.prefix)+len(line)+1)
+	if pw.stamp != nil {
+		b = append(b, pw.stamp()...)
+	}
+	b = append(b, pw.prefix...)
+	b = append(b, pw.render(line)...)
+	b = append(b, '\n')
+	_, err := pw.w.Write(b)
+	return err
+}
+
+// Flush writes the held back partial line, if any, terminating it with a newline
+func (pw *PrefixedWriter) Flush() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.buf.Len() == 0 {
+		return nil
+	}
+	defer pw.buf.Reset()
+	return pw.writeLine(pw.buf.Bytes())
+}
+
+// setTTY marks output as coming from a pseudo terminal
+func (pw *PrefixedWriter) setTTY() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.tty = true
 }
 
 var _ io.Writer = (*PrefixedWriter)(nil)
 
+// setTTY marks w, or writers it writes to, as receiving output of a pseudo terminal
+func setTTY(w io.Writer) {
+	switch w := w.(type) {
+	case *PrefixedWriter:
+		w.setTTY()
+	case teeWriter:
+		for _, tw := range w {
+			setTTY(tw)
+		}
+	}
+}
+
+// withPrefix prefixes every line with `[prefix]`, in color (picked from palette, when empty),
+// and pads it to width, so that output of tasks running alongside is aligned
+func withPrefix(w io.Writer, prefix string, color types.Color, width int) *PrefixedWriter {
+	if prefix != "" {
+		prefix = types.GetColoredPrefix(prefix, prefixColor(prefix, color), width)
+	}
+
+	return &PrefixedWriter{
+		w:      w,
+		prefix: []byte(prefix),
+		buf:    bytes.NewBuffer(nil),
+		render: func(b []byte) []byte { return b },
+	}
+}
+
+// prefixColor is ANSI code of color, or of the palette color of prefix, when color is empty
+func prefixColor(prefix string, color types.Color) string {
+	if c, err := color.ANSI(); err == nil {
+		return c
+	}
+	return types.PrefixColor(prefix)
+}
+
+func withDimmedPrefix(w io.Writer, prefix string) *PrefixedWriter {
+	if prefix != "" {
+		prefix = types.GetDimStyledPrefix(prefix)
+	}
+
+	return &PrefixedWriter{
+		w:      w,
+		prefix: []byte(prefix),
+		buf:    bytes.NewBuffer(nil),
+		render: func(b []byte) []byte { return []byte(types.GetDimmedText(b)) },
+	}
+}
+
+// timestamper returns what lines are stamped with, as per mode, relative timestamps are since started
+func timestamper(mode types.TimestampMode, started time.Time, styled bool) func() string {
+	style := func(s string) string {
+		if !styled {
+			return s
+		}
+		return types.GetGrayText(s)
+	}
+
+	switch mode {
+	case types.TimestampAbsolute:
+		return func() string { return style(time.Now().Format("15:04:05.000")) + " " }
+	case types.TimestampRelative:
+		return func() string {
+			return style(fmt.Sprintf("%10s", fmt.Sprintf("+%.3fs", time.Since(started).Seconds()))) + " "
+		}
+	}
+	return nil
+}
+
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+func stripANSI(b []byte) []byte {
+	return ansiSequence.ReplaceAll(b, nil)
+}
+
+// logMu serializes rotation of log files, as parallel runs of a task share them
+var logMu sync.Mutex
+
+// openTaskLog creates log file of a task, rotating the previous ones
+func openTaskLog(l types.TaskLog) (*os.File, error) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.File), 0o755); err != nil {
+		return nil, errors.ErrTaskLogFile.Wrap(err).KV("file", l.File)
+	}
+
+	if l.Rotate > 0 {
+		for i := l.Rotate - 1; i >= 0; i-- {
+			from := l.File
+			if i > 0 {
+				from = fmt.Sprintf("%s.%d", l.File, i)
+			}
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", l.File, i+1)); err != nil && !os.IsNotExist(err) {
+				return nil, errors.ErrTaskLogFile.Wrap(err).KV("file", l.File)
+			}
+		}
+	}
+
+	f, err := os.Create(l.File)
+	if err != nil {
+		return nil, errors.ErrTaskLogFile.Wrap(err).KV("file", l.File)
+	}
+	return f, nil
+}
+
+// teeWriter writes to all of its writers, and flushes the ones that can be
+type teeWriter []io.Writer
+
+// Write implements io.Writer.
+func (t teeWriter) Write(p []byte) (int, error) {
+	for _, w := range t {
+		if _, err := w.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (t teeWriter) Flush() error {
+	for _, w := range t {
+		if f, ok := w.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 type LogWriter struct {
 	w  io.Writer
 	mu sync.Mutex
@@ -56,27 +237,158 @@ func (s *LogWriter) Write(p []byte) (n int, err error) {
 var _ io.Writer = (*LogWriter)(nil)
 
 func (s *LogWriter) WithPrefix(prefix string) io.Writer {
-	if prefix != "" && hasANSISupport() {
-		prefix = types.GetStyledPrefix(prefix)
+	return withPrefix(s, prefix, "", 0)
+}
+
+func (s *LogWriter) WithDimmedPrefix(prefix string) io.Writer {
+	return withDimmedPrefix(s, prefix)
+}
+
+// withStderrPrefix is like withPrefix, but marks prefix with a `!`, so that stderr of a task stands apart from its stdout.
+// Without colors, stderr lines are prefixed just like stdout ones, as output is then rarely seen interleaved
+func withStderrPrefix(w io.Writer, prefix string, color types.Color, width int) *PrefixedWriter {
+	if !terminal.Detect().Color {
+		return withPrefix(w, prefix, color, width)
+	}
+
+	if prefix != "" {
+		prefix = types.GetStderrPrefix(prefix, prefixColor(prefix, color), width)
 	}
 
 	return &PrefixedWriter{
-		w:      s.w,
+		w:      w,
 		prefix: []byte(prefix),
 		buf:    bytes.NewBuffer(nil),
 		render: func(b []byte) []byte { return b },
 	}
 }
 
-func (s *LogWriter) WithDimmedPrefix(prefix string) io.Writer {
-	if prefix != "" && hasANSISupport() {
-		prefix = types.GetDimStyledPrefix(prefix)
+// outputChunk is a single write, to either stdout or stderr of a task
+type outputChunk struct {
+	stderr bool
+	data   []byte
+}
+
+// taskOutput is where output of a task's commands, and of the tasks it runs, is written to.
+// Depending on its mode, it either streams to its parent, or buffers, until the task finishes.
+// stdout and stderr are kept apart, and when buffered, are replayed in the order they were written
+type taskOutput struct {
+	mode   types.OutputMode
+	stdout io.Writer
+	stderr io.Writer
+
+	// timestamps are added to every line, and relative ones are since started
+	timestamps types.TimestampMode
+	started    time.Time
+
+	// log, when set, gets output of the task's own commands, without ANSI codes
+	log io.Writer
+
+	buffered bool
+	mu       sync.Mutex
+	chunks   []outputChunk
+}
+
+func newTaskOutput(stdout, stderr io.Writer, mode types.OutputMode) *taskOutput {
+	return &taskOutput{
+		mode:     mode,
+		stdout:   stdout,
+		stderr:   stderr,
+		started:  time.Now(),
+		buffered: mode == types.OutputGrouped || mode == types.OutputQuiet,
 	}
+}
 
-	return &PrefixedWriter{
-		w:      s.w,
-		prefix: []byte(prefix),
-		buf:    bytes.NewBuffer(nil),
-		render: func(b []byte) []byte { return []byte(types.GetDimmedText(b)) },
+func (o *taskOutput) write(stderr bool, p []byte) (int, error) {
+	if !o.buffered {
+		if stderr {
+			return o.stderr.Write(p)
+		}
+		return o.stdout.Write(p)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.chunks = append(o.chunks, outputChunk{stderr: stderr, data: append([]byte{}, p...)})
+	return len(p), nil
+}
+
+// taskStream is either stdout, or stderr of a taskOutput
+type taskStream struct {
+	o      *taskOutput
+	stderr bool
+}
+
+// Write implements io.Writer.
+func (s taskStream) Write(p []byte) (int, error) {
+	return s.o.write(s.stderr, p)
+}
+
+var _ io.Writer = taskStream{}
+
+// Stdout is stdout of the task
+func (o *taskOutput) Stdout() io.Writer {
+	return taskStream{o: o}
+}
+
+// Stderr is stderr of the task, command previews are written to it too, so that stdout stays clean for piping
+func (o *taskOutput) Stderr() io.Writer {
+	return taskStream{o: o, stderr: true}
+}
+
+// child creates output for a task, run by this one. It inherits timestamps, but not the log
+func (o *taskOutput) child(mode types.OutputMode) *taskOutput {
+	c := newTaskOutput(o.Stdout(), o.Stderr(), mode)
+	c.timestamps = o.timestamps
+	return c
+}
+
+// commandWriters are what a command of the task writes its stdout, and stderr to. Except in raw mode,
+// every line is prefixed with task name, padded to prefixWidth, and timestamped if enabled
+func (o *taskOutput) commandWriters(taskName string, color types.Color, prefixWidth int) (stdout io.Writer, stderr io.Writer) {
+	stdout, stderr = o.Stdout(), o.Stderr()
+	if o.mode != types.OutputRaw {
+		opw, epw := withPrefix(stdout, taskName, color, prefixWidth), withStderrPrefix(stderr, taskName, color, prefixWidth)
+		opw.stamp = timestamper(o.timestamps, o.started, true)
+		epw.stamp = opw.stamp
+		stdout, stderr = opw, epw
+	}
+
+	if o.log == nil {
+		return stdout, stderr
+	}
+
+	logWriter := func() io.Writer {
+		return &PrefixedWriter{w: o.log, buf: bytes.NewBuffer(nil), render: stripANSI, stamp: timestamper(o.timestamps, o.started, false)}
+	}
+	return teeWriter{stdout, logWriter()}, teeWriter{stderr, logWriter()}
+}
+
+// finish flushes buffered output to parent. In quiet mode, output is flushed
+// only when the task failed, and is discarded otherwise
+func (o *taskOutput) finish(failed bool) error {
+	if !o.buffered {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	chunks := o.chunks
+	o.chunks = nil
+
+	if o.mode == types.OutputQuiet && !failed {
+		return nil
+	}
+
+	for _, c := range chunks {
+		w := o.stdout
+		if c.stderr {
+			w = o.stderr
+		}
+		if _, err := w.Write(c.data); err != nil {
+			return err
+		}
 	}
+	return nil
 }
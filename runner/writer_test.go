@@ -0,0 +1,243 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nxtcoder17/runfile/terminal"
+	"github.com/nxtcoder17/runfile/types"
+)
+
+func Test_TaskOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   types.OutputMode
+		failed bool
+
+		// wantBeforeFinish is what parent's stdout, and stderr have, before task finishes
+		wantBeforeFinish [2]string
+		want             [2]string
+	}{
+		{
+			name:             "1. prefixed, streams lines with prefix",
+			mode:             types.OutputPrefixed,
+			wantBeforeFinish: [2]string{"[test] hello\n", "[test] oops\n"},
+			want:             [2]string{"[test] hello\n", "[test] oops\n"},
+		},
+		{
+			name:             "2. raw, streams as is",
+			mode:             types.OutputRaw,
+			wantBeforeFinish: [2]string{"hello\n", "oops\n"},
+			want:             [2]string{"hello\n", "oops\n"},
+		},
+		{
+			name: "3. grouped, flushes when task finishes",
+			mode: types.OutputGrouped,
+			want: [2]string{"[test] hello\n", "[test] oops\n"},
+		},
+		{
+			name: "4. quiet, discards output of a successful task",
+			mode: types.OutputQuiet,
+		},
+		{
+			name:   "5. quiet, flushes output of a failed task",
+			mode:   types.OutputQuiet,
+			failed: true,
+			want:   [2]string{"[test] hello\n", "[test] oops\n"},
+		},
+	}
+
+	terminal.Configure(terminal.Options{Color: terminal.ColorNever})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+			o := newTaskOutput(stdout, stderr, tt.mode)
+
+			ow, ew := o.commandWriters("test", "", 0)
+			if _, err := ow.Write([]byte("hello\n")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ew.Write([]byte("oops\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := [2]string{stdout.String(), stderr.String()}; got != tt.wantBeforeFinish {
+				t.Errorf("before finish, got = %q, want = %q", got, tt.wantBeforeFinish)
+			}
+
+			if err := o.finish(tt.failed); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := [2]string{stdout.String(), stderr.String()}; got != tt.want {
+				t.Errorf("after finish, got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TaskOutputOrdering(t *testing.T) {
+	terminal.Configure(terminal.Options{Color: terminal.ColorNever})
+
+	// INFO: with both streams going to the same writer, order of writes across them must be kept
+	b := new(bytes.Buffer)
+	o := newTaskOutput(b, b, types.OutputGrouped)
+	ow, ew := o.commandWriters("test", "", 0)
+
+	ow.Write([]byte("one\n"))
+	ew.Write([]byte("two\n"))
+	ow.Write([]byte("three\n"))
+
+	if err := o.finish(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "[test] one\n[test] two\n[test] three\n"; b.String() != want {
+		t.Errorf("got = %q, want = %q", b.String(), want)
+	}
+}
+
+func Test_PrefixedWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		tty    bool
+		writes []string
+		flush  bool
+		want   string
+	}{
+		{
+			name:   "1. lines split across writes",
+			writes: []string{"hel", "lo\nwor", "ld\n"},
+			want:   "[test] hello\n[test] world\n",
+		},
+		{
+			name:   "2. partial line is held back, until flushed",
+			writes: []string{"hello\nno newline"},
+			want:   "[test] hello\n",
+		},
+		{
+			name:   "3. flushing writes partial line, with a newline",
+			writes: []string{"hello\nno newline"},
+			flush:  true,
+			want:   "[test] hello\n[test] no newline\n",
+		},
+		{
+			name:   "4. pty output, with carriage returns, keeps only final state of a line",
+			tty:    true,
+			writes: []string{"10%\r50%\r", "100%\r\n"},
+			want:   "[test] 100%\n",
+		},
+		{
+			name:   "5. piped output, with carriage returns, is kept as is",
+			writes: []string{"10%\r50%\r", "100%\n"},
+			want:   "[test] 10%\r50%\r100%\n",
+		},
+		{
+			name:   "6. prefix is padded to width",
+			width:  8,
+			writes: []string{"hello\n"},
+			want:   "[test]     hello\n",
+		},
+	}
+
+	terminal.Configure(terminal.Options{Color: terminal.ColorNever})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := new(bytes.Buffer)
+			pw := withPrefix(b, "test", "", tt.width)
+			pw.tty = tt.tty
+			for _, w := range tt.writes {
+				if _, err := pw.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.flush {
+				if err := pw.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := b.String(); got != tt.want {
+				t.Errorf("got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_StderrPrefix(t *testing.T) {
+	tests := []struct {
+		name  string
+		color terminal.ColorMode
+		want  string
+	}{
+		{
+			name:  "1. with colors, stderr prefix is in task's color, and marked",
+			color: terminal.ColorAlways,
+			want:  "\x1b[34m[test]!\x1b[0m    oops\n",
+		},
+		{
+			name:  "2. without colors, stderr prefix is same as stdout one",
+			color: terminal.ColorNever,
+			want:  "[test]     oops\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terminal.Configure(terminal.Options{Color: tt.color})
+			t.Cleanup(func() { terminal.Configure(terminal.Options{Color: terminal.ColorNever}) })
+
+			b := new(bytes.Buffer)
+			pw := withStderrPrefix(b, "test", types.Color("blue"), 8)
+			if _, err := pw.Write([]byte("oops\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := b.String(); got != tt.want {
+				t.Errorf("got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TaskLog(t *testing.T) {
+	terminal.Configure(terminal.Options{Color: terminal.ColorNever})
+
+	file := filepath.Join(t.TempDir(), "logs", "test.log")
+
+	// INFO: with rotate 2, only the last 2 previous logs are kept
+	for run := 1; run <= 4; run++ {
+		f, err := openTaskLog(types.TaskLog{File: file, Rotate: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		o := newTaskOutput(new(bytes.Buffer), new(bytes.Buffer), types.OutputPrefixed)
+		o.log = f
+
+		ow, _ := o.commandWriters("test", "", 0)
+		fmt.Fprintf(ow, "\033[31mrun %d\033[0m\n", run)
+		f.Close()
+	}
+
+	for suffix, want := range map[string]string{"": "run 4\n", ".1": "run 3\n", ".2": "run 2\n"} {
+		b, err := os.ReadFile(file + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("log%s, got = %q, want = %q", suffix, b, want)
+		}
+	}
+
+	if _, err := os.Stat(file + ".3"); !os.IsNotExist(err) {
+		t.Errorf("log.3 must not exist, got err = %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// ColorMode decides whether output is colored
+type ColorMode string
+
+const (
+	// ColorAuto colors output, only when stdout is a terminal, and neither `NO_COLOR` nor `TERM=dumb` is set.
+	// `FORCE_COLOR` colors output, even when stdout is not a terminal
+	ColorAuto   ColorMode = "auto"
+	ColorAlways ColorMode = "always"
+	ColorNever  ColorMode = "never"
+)
+
+func (m ColorMode) IsValid() bool {
+	return m == ColorAuto || m == ColorAlways || m == ColorNever
+}
+
+const (
+	// DefaultDarkTheme, and DefaultLightTheme are chroma themes, commands are highlighted with,
+	// as per terminal's background. See [chroma playground](https://swapoff.org/chroma/playground/)
+	DefaultDarkTheme  = "catppuccin-macchiato"
+	DefaultLightTheme = "monokailight"
+)
+
+// ValidateTheme checks that theme is one of chroma's themes
+func ValidateTheme(theme string) error {
+	if !slices.Contains(styles.Names(), theme) {
+		return fmt.Errorf("invalid theme (%s), see https://swapoff.org/chroma/playground/ for available themes", theme)
+	}
+	return nil
+}
+
+type Options struct {
+	Color ColorMode
+
+	// Theme is the chroma theme, commands are highlighted with. When empty, it is picked as per terminal's background
+	Theme string
+}
+
+// Capabilities of the terminal, run is attached to
+type Capabilities struct {
+	// Color is true, when output should be colored
+	Color bool
+
+	StdinTTY  bool
+	StdoutTTY bool
+	StderrTTY bool
+
+	// StdinPiped is true, when `run` is being piped into, or its stdin is redirected from a file
+	StdinPiped bool
+
+	DarkBackground bool
+
+	// Theme is the chroma theme, for highlighting commands
+	Theme string
+}
+
+var (
+	mu   sync.Mutex
+	opts = Options{Color: ColorAuto}
+	caps *Capabilities
+)
+
+// Configure sets options, and detects capabilities with them. It should be called before anything is printed,
+// otherwise capabilities are detected with default options, on first call to Detect
+func Configure(o Options) {
+	mu.Lock()
+	defer mu.Unlock()
+	opts = o
+	load()
+}
+
+// Detect returns capabilities of the terminal, detecting them on first call
+func Detect() Capabilities {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if caps == nil {
+		load()
+	}
+	return *caps
+}
+
+// load detects capabilities with opts, and applies them to lipgloss, whose settings are global,
+// so that they are never modified while output is being rendered. mu must be held
+func load() {
+	c := detect(opts)
+	caps = &c
+
+	// INFO: lipgloss detects these on its own, which would disregard `--color`
+	profile := termenv.Ascii
+	if c.Color {
+		profile = termenv.TrueColor
+	}
+	lipgloss.SetColorProfile(profile)
+	lipgloss.SetHasDarkBackground(c.DarkBackground)
+}
+
+// sgr matches ANSI escape sequences, that set colors, and styles of text
+var sgr = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+type colorWriter struct {
+	w io.Writer
+}
+
+func (cw colorWriter) Write(p []byte) (int, error) {
+	if Detect().Color {
+		return cw.w.Write(p)
+	}
+
+	if _, err := cw.w.Write(sgr.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ColorWriter writes to w, with colors stripped, when output should not be colored. It is meant for writers,
+// that color their output unconditionally, e.g. loggers, and expects escape sequences not to be split across writes
+func ColorWriter(w io.Writer) io.Writer {
+	return colorWriter{w: w}
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+func isPiped(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeNamedPipe != 0 || fi.Mode().IsRegular()
+}
+
+func detect(o Options) Capabilities {
+	c := Capabilities{
+		StdinTTY:       isTerminal(os.Stdin),
+		StdinPiped:     isPiped(os.Stdin),
+		StdoutTTY:      isTerminal(os.Stdout),
+		StderrTTY:      isTerminal(os.Stderr),
+		DarkBackground: true,
+	}
+
+	c.Color = colorEnabled(o.Color, os.Getenv, c.StdoutTTY)
+
+	// INFO: querying background writes to, and reads from the terminal, so it is only done when it is one
+	if c.Color && c.StdoutTTY {
+		c.DarkBackground = termenv.NewOutput(os.Stdout).HasDarkBackground()
+	}
+
+	c.Theme = o.Theme
+	if c.Theme == "" {
+		c.Theme = DefaultDarkTheme
+		if !c.DarkBackground {
+			c.Theme = DefaultLightTheme
+		}
+	}
+
+	return c
+}
+
+// colorEnabled decides whether output is colored. An explicit mode (always|never) wins over everything,
+// then `NO_COLOR`, then `FORCE_COLOR`, and then whether stdout is a terminal
+func colorEnabled(mode ColorMode, getenv func(string) string, stdoutTTY bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	if v := getenv("FORCE_COLOR"); v != "" && v != "0" && v != "false" {
+		return true
+	}
+
+	return stdoutTTY && getenv("TERM") != "dumb"
+}
@@ -0,0 +1,122 @@
+package terminal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_ColorEnabled(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      ColorMode
+		env       map[string]string
+		stdoutTTY bool
+		want      bool
+	}{
+		{
+			name:      "1. auto, on a terminal",
+			mode:      ColorAuto,
+			stdoutTTY: true,
+			want:      true,
+		},
+		{
+			name:      "2. auto, when output is redirected",
+			mode:      ColorAuto,
+			stdoutTTY: false,
+			want:      false,
+		},
+		{
+			name:      "3. auto, with NO_COLOR",
+			mode:      ColorAuto,
+			env:       map[string]string{"NO_COLOR": "1"},
+			stdoutTTY: true,
+			want:      false,
+		},
+		{
+			name:      "4. auto, with FORCE_COLOR, when output is redirected",
+			mode:      ColorAuto,
+			env:       map[string]string{"FORCE_COLOR": "1"},
+			stdoutTTY: false,
+			want:      true,
+		},
+		{
+			name:      "5. auto, with FORCE_COLOR=0",
+			mode:      ColorAuto,
+			env:       map[string]string{"FORCE_COLOR": "0"},
+			stdoutTTY: false,
+			want:      false,
+		},
+		{
+			name:      "6. NO_COLOR wins over FORCE_COLOR",
+			mode:      ColorAuto,
+			env:       map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"},
+			stdoutTTY: true,
+			want:      false,
+		},
+		{
+			name:      "7. auto, with TERM=dumb",
+			mode:      ColorAuto,
+			env:       map[string]string{"TERM": "dumb"},
+			stdoutTTY: true,
+			want:      false,
+		},
+		{
+			name:      "8. always, wins over NO_COLOR",
+			mode:      ColorAlways,
+			env:       map[string]string{"NO_COLOR": "1"},
+			stdoutTTY: false,
+			want:      true,
+		},
+		{
+			name:      "9. never, wins over FORCE_COLOR",
+			mode:      ColorNever,
+			env:       map[string]string{"FORCE_COLOR": "1"},
+			stdoutTTY: true,
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			if got := colorEnabled(tt.mode, getenv, tt.stdoutTTY); got != tt.want {
+				t.Errorf("colorEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ColorWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		color ColorMode
+		want  string
+	}{
+		{
+			name:  "1. colors are kept, when output is colored",
+			color: ColorAlways,
+			want:  "\x1b[32mINFO\x1b[0m started",
+		},
+		{
+			name:  "2. colors are stripped, when output is not colored",
+			color: ColorNever,
+			want:  "INFO started",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Configure(Options{Color: tt.color})
+			t.Cleanup(func() { Configure(Options{Color: ColorAuto}) })
+
+			var buf bytes.Buffer
+			if _, err := ColorWriter(&buf).Write([]byte("\x1b[32mINFO\x1b[0m started")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("ColorWriter(), got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,13 @@
 package types
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/nxtcoder17/runfile/terminal"
+)
 
 const (
 	StyleReset   = "\033[0m"
@@ -11,26 +18,124 @@ const (
 	// StyleFgGray  = "\033[2;30m"
 )
 
+// styled wraps text in style, only when terminal supports colors
+func styled(style string, text string) string {
+	if style == "" || !terminal.Detect().Color {
+		return text
+	}
+	return style + text + StyleReset
+}
+
 func GetStyledPrefix(prefix string) string {
-	return fmt.Sprintf("%s[%s]%s ", StyleFgGreen, prefix, StyleReset)
+	return styled(StyleFgGreen, "["+prefix+"]") + " "
 	// return fmt.Sprintf("%s%s |%s ", Green, prefix, Reset)
 }
 
 func GetDimStyledPrefix(prefix string) string {
-	return fmt.Sprintf("%s[%s]%s ", "\033[3;36m", prefix, StyleReset)
+	return styled("\033[3;36m", "["+prefix+"]") + " "
 	// return fmt.Sprintf("%s%s |%s ", Green, prefix, Reset)
 }
 
 func GetDimmedText(text []byte) string {
-	return fmt.Sprintf("%s%s%s", "\033[0;36m", text, StyleReset)
+	return styled("\033[0;36m", string(text))
 	// return fmt.Sprintf("%s%s%s", StyleFgGray, text, StyleReset)
 }
 
+func GetGrayText(text string) string {
+	return styled(StyleFgGray, text)
+}
+
 func GetCommandHighlight(text []byte) string {
-	return fmt.Sprintf("%s%s%s", StyleFgGreen, text, StyleReset)
+	return styled(StyleFgGreen, string(text))
 }
 
 func GetErrorStyledPrefix(prefix string) string {
-	return fmt.Sprintf("%s[%s]%s ", StyleFgRed, prefix, StyleReset)
+	return styled(StyleFgRed, "["+prefix+"]") + " "
 	// return fmt.Sprintf("%s%s |%s ", Green, prefix, Reset)
 }
+
+// prefixPalette are colors, task prefixes are picked from. Red is left out, as it is used for errors
+var prefixPalette = []string{
+	"\033[32m", // green
+	"\033[33m", // yellow
+	"\033[34m", // blue
+	"\033[35m", // magenta
+	"\033[36m", // cyan
+	"\033[92m", // bright green
+	"\033[93m", // bright yellow
+	"\033[94m", // bright blue
+	"\033[95m", // bright magenta
+	"\033[96m", // bright cyan
+}
+
+var namedColors = map[string]string{
+	"black":          "\033[30m",
+	"red":            "\033[31m",
+	"green":          "\033[32m",
+	"yellow":         "\033[33m",
+	"blue":           "\033[34m",
+	"magenta":        "\033[35m",
+	"cyan":           "\033[36m",
+	"white":          "\033[37m",
+	"gray":           "\033[90m",
+	"bright-red":     "\033[91m",
+	"bright-green":   "\033[92m",
+	"bright-yellow":  "\033[93m",
+	"bright-blue":    "\033[94m",
+	"bright-magenta": "\033[95m",
+	"bright-cyan":    "\033[96m",
+	"bright-white":   "\033[97m",
+}
+
+// Color is either one of the named colors (e.g. `cyan`, `bright-blue`), or a hex color (e.g. `#ff8800`)
+type Color string
+
+// ANSI returns escape sequence for the color
+func (c Color) ANSI() (string, error) {
+	if code, ok := namedColors[string(c)]; ok {
+		return code, nil
+	}
+
+	var r, g, b uint8
+	if len(c) == 7 {
+		if _, err := fmt.Sscanf(string(c), "#%02x%02x%02x", &r, &g, &b); err == nil {
+			return fmt.Sprintf("\033[38;2;%d;%d;%dm", r, g, b), nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid color (%s), must be either a hex color like #ff8800, or one of the named colors, like cyan, bright-blue", c)
+}
+
+// UnmarshalJSON implements custom unmarshaling for Color
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid color format: %w", err)
+	}
+
+	if s != "" {
+		if _, err := Color(s).ANSI(); err != nil {
+			return err
+		}
+	}
+
+	*c = Color(s)
+	return nil
+}
+
+// PrefixColor picks a color from palette by hashing name, so that a task always gets the same color
+func PrefixColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return prefixPalette[h.Sum32()%uint32(len(prefixPalette))]
+}
+
+// GetColoredPrefix styles prefix in color, padding it with spaces to be at least width characters long
+func GetColoredPrefix(prefix string, color string, width int) string {
+	return styled(color, "["+prefix+"]") + " " + strings.Repeat(" ", max(width-len(prefix), 0))
+}
+
+// GetStderrPrefix is like GetColoredPrefix, but marks prefix with a `!`, which takes up one of its padding spaces
+func GetStderrPrefix(prefix string, color string, width int) string {
+	return styled(color, "["+prefix+"]!") + " " + strings.Repeat(" ", max(width-len(prefix)-1, 0))
+}
@@ -11,6 +11,11 @@ type Context struct {
 	log.Logger
 	TaskName      string
 	TaskNamespace string
+
+	// DryRun, when true, parser does not start any process i.e. `requires` checks are skipped,
+	// and env vars with `sh` key are not evaluated, unless EvalEnv is true
+	DryRun  bool
+	EvalEnv bool
 }
 
 func NewContext(ctx context.Context, logger log.Logger) Context {
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is either a duration string like `300ms`, or `2s`, or a number of milliseconds
+type Duration time.Duration
+
+// UnmarshalJSON implements custom unmarshaling for Duration
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid duration format: %w", err)
+	}
+
+	switch val := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(val) * time.Millisecond)
+	case string:
+		dur, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid duration (%s), must be like 300ms, or 2s", val)
+		}
+		*d = Duration(dur)
+	default:
+		return fmt.Errorf("invalid duration, must be either a string like 300ms, or a number of milliseconds")
+	}
+
+	if *d < 0 {
+		return fmt.Errorf("invalid duration, must not be negative")
+	}
+	return nil
+}
+
+// DurationOr returns d, or dv when d is nil
+func DurationOr(d *Duration, dv time.Duration) time.Duration {
+	if d == nil {
+		return dv
+	}
+	return time.Duration(*d)
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OutputMode decides how output of a task's commands is shown
+type OutputMode string
+
+const (
+	// OutputPrefixed streams every line, prefixed with task name
+	OutputPrefixed OutputMode = "prefixed"
+
+	// OutputGrouped buffers output of a task, and shows it all at once, when the task finishes
+	OutputGrouped OutputMode = "grouped"
+
+	// OutputRaw streams output as is, without any prefix
+	OutputRaw OutputMode = "raw"
+
+	// OutputQuiet buffers output of a task, and shows it, only if the task fails
+	OutputQuiet OutputMode = "quiet"
+)
+
+var OutputModes = []OutputMode{OutputPrefixed, OutputGrouped, OutputRaw, OutputQuiet}
+
+func (m OutputMode) IsValid() bool {
+	switch m {
+	case OutputPrefixed, OutputGrouped, OutputRaw, OutputQuiet:
+		return true
+	}
+	return false
+}
+
+// UnmarshalJSON implements custom unmarshaling for OutputMode
+func (m *OutputMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid output format: %w", err)
+	}
+
+	if s != "" && !OutputMode(s).IsValid() {
+		return fmt.Errorf("invalid output (%s), must be one of %v", s, OutputModes)
+	}
+
+	*m = OutputMode(s)
+	return nil
+}
+
+// TimestampMode decides how output lines are timestamped, lines are not timestamped when empty
+type TimestampMode string
+
+const (
+	// TimestampAbsolute is wall clock time, a line was written at
+	TimestampAbsolute TimestampMode = "absolute"
+
+	// TimestampRelative is time since the task started
+	TimestampRelative TimestampMode = "relative"
+)
+
+func (m TimestampMode) IsValid() bool {
+	switch m {
+	case "", TimestampAbsolute, TimestampRelative:
+		return true
+	}
+	return false
+}
+
+// EchoMode decides how a command is shown, before it is executed
+type EchoMode string
+
+const (
+	// EchoBox shows command, syntax highlighted in a box, only when output is a terminal
+	EchoBox EchoMode = "box"
+
+	// EchoLine shows first line of command, prefixed with task name
+	EchoLine EchoMode = "line"
+
+	// EchoNone does not show command
+	EchoNone EchoMode = "none"
+)
+
+var EchoModes = []EchoMode{EchoBox, EchoLine, EchoNone}
+
+func (m EchoMode) IsValid() bool {
+	switch m {
+	case EchoBox, EchoLine, EchoNone:
+		return true
+	}
+	return false
+}
+
+// UnmarshalJSON implements custom unmarshaling for EchoMode
+func (m *EchoMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid echo format: %w", err)
+	}
+
+	if s != "" && !EchoMode(s).IsValid() {
+		return fmt.Errorf("invalid echo (%s), must be one of %v", s, EchoModes)
+	}
+
+	*m = EchoMode(s)
+	return nil
+}
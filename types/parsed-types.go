@@ -7,6 +7,9 @@ type ParsedRunfile struct {
 
 	Metadata struct {
 		RunfilePath string
+
+		// Files are all the files, this runfile is parsed from, i.e. itself, its includes, and dotenv files
+		Files []string
 	}
 }
 
@@ -22,11 +25,25 @@ type ParsedTask struct {
 	Watch       *TaskWatch        `json:"watch,omitempty"`
 	Env         map[string]string `json:"environ"`
 	Interactive bool              `json:"interactive,omitempty"`
+	TTY         bool              `json:"tty,omitempty"`
+	Stdin       *Stdin            `json:"stdin,omitempty"`
+	Log         *TaskLog          `json:"log,omitempty"`
+	Output      OutputMode        `json:"output,omitempty"`
+	Echo        EchoMode          `json:"echo,omitempty"`
+	Color       Color             `json:"color,omitempty"`
+	IgnoreError bool              `json:"ignoreError,omitempty"`
+	PassArgs    bool              `json:"passArgs,omitempty"`
+	Template    bool              `json:"template,omitempty"`
 
 	// Parallel allows you to run commands or run targets in parallel
-	Parallel bool `json:"parallel"`
+	Parallel    bool `json:"parallel"`
+	FailFast    bool `json:"failFast"`
+	MaxParallel int  `json:"maxParallel,omitempty"`
+	Weight      int  `json:"weight,omitempty"`
+	Priority    int  `json:"priority,omitempty"`
 
 	Commands []ParsedCommandJson `json:"commands"`
+	Finally  []ParsedCommandJson `json:"finally,omitempty"`
 }
 
 type ParsedCommandJson struct {
@@ -36,6 +53,16 @@ type ParsedCommandJson struct {
 
 	// If is a go template expression, which must evaluate to true, for task to run
 	If *bool `json:"if"`
+
+	IgnoreError bool `json:"ignoreError,omitempty"`
+
+	// Defer marks Command to be run, only after all other commands of the task have finished
+	Defer bool `json:"defer,omitempty"`
+
+	// Echo is how command is shown before it runs, `silent: true` is resolved to EchoNone
+	Echo EchoMode `json:"echo,omitempty"`
+
+	Stdin *Stdin `json:"stdin,omitempty"`
 }
 
 type ParsedIncludeSpec struct {
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type StdinMode string
+
+const (
+	// StdinInherit passes stdin of `run` itself to the command
+	StdinInherit StdinMode = "inherit"
+
+	// StdinNone gives command an empty stdin
+	StdinNone StdinMode = "none"
+
+	// StdinFile reads stdin from a file
+	StdinFile StdinMode = "file"
+
+	// StdinLiteral is a fixed input, written to command's stdin
+	StdinLiteral StdinMode = "literal"
+)
+
+// Stdin is what a command reads as its stdin. In runfile, it is one of
+//   - `inherit` (or `true`), passes stdin of `run` itself
+//   - `none` (or `false`), empty stdin
+//   - `file:<path>`, reads from file at path
+//   - any other string, as is
+type Stdin struct {
+	Mode StdinMode
+
+	// Value is the path for StdinFile, and the input itself for StdinLiteral
+	Value string
+}
+
+// UnmarshalJSON implements custom unmarshaling for Stdin
+func (s *Stdin) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid stdin format: %w", err)
+	}
+
+	switch val := v.(type) {
+	case bool:
+		*s = Stdin{Mode: StdinNone}
+		if val {
+			*s = Stdin{Mode: StdinInherit}
+		}
+	case string:
+		switch {
+		case val == string(StdinInherit), val == string(StdinNone):
+			*s = Stdin{Mode: StdinMode(val)}
+		case strings.HasPrefix(val, "file:"):
+			path := strings.TrimSpace(strings.TrimPrefix(val, "file:"))
+			if path == "" {
+				return fmt.Errorf("invalid stdin, file path must be set, as file:<path>")
+			}
+			*s = Stdin{Mode: StdinFile, Value: path}
+		default:
+			*s = Stdin{Mode: StdinLiteral, Value: val}
+		}
+	default:
+		return fmt.Errorf("invalid stdin, must be either a boolean, or one of inherit, none, file:<path>, or any other text")
+	}
+
+	return nil
+}
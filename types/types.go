@@ -1,5 +1,10 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type Runfile struct {
 	Filepath string                 `json:"-"`
 	Version  string                 `json:"version,omitempty"`
@@ -42,10 +47,96 @@ type TaskWatch struct {
 	IgnoreDirs       []string `json:"ignoreDirs"`
 	Extensions       []string `json:"extensions"`
 	IgnoreExtensions []string `json:"ignoreExtensions"`
-	SSE              *struct {
+	// SSE, when set, streams events of this task as it starts, and finishes, and serves `/livereload.js` at Addr.
+	// Watched tasks with the same Addr, share one server
+	SSE *struct {
 		Addr string `json:"addr"`
 	} `json:"sse,omitempty"`
 	// ExcludeDirs []string `json:"excludeDirs"`
+
+	// Include, and Exclude are doublestar globs (i.e. `**/*.go`), matched against paths relative to task's working dir.
+	// Patterns without a `/` match file name in any dir. When Include is set, only files matching it rerun the task
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Gitignore, when true, skips files ignored by `.gitignore` files found in the watched dirs
+	Gitignore bool `json:"gitignore,omitempty"`
+
+	// Debounce is the quiet period, file changes are batched over, before task is rerun. Default: 100ms
+	Debounce *Duration `json:"debounce,omitempty"`
+
+	// RunOnStart, when false, waits for the first change, before running the task. Default: true
+	RunOnStart *bool `json:"runOnStart,omitempty"`
+
+	// Policy decides what happens to changes, while task is running. Default: restart
+	Policy WatchPolicy `json:"policy,omitempty"`
+
+	// ShutdownTimeout is the time, previous run gets to exit gracefully, before it is force killed. Default: 5s
+	ShutdownTimeout *Duration `json:"shutdownTimeout,omitempty"`
+
+	// On routes changed files to other tasks, as glob -> task name. A file matching any of the globs,
+	// runs those tasks instead of this one. Globs are matched like Include
+	On map[string]string `json:"on,omitempty"`
+}
+
+// UnmarshalJSON implements custom unmarshaling for TaskWatch, as YAML 1.1 reads `on` key as `true`
+func (w *TaskWatch) UnmarshalJSON(data []byte) error {
+	type taskWatch TaskWatch
+	var v struct {
+		taskWatch
+		OnYAML map[string]string `json:"true,omitempty"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*w = TaskWatch(v.taskWatch)
+	if w.On == nil {
+		w.On = v.OnYAML
+	}
+	return nil
+}
+
+// WatchPolicy decides what happens to file changes, while a watched task is running
+type WatchPolicy string
+
+const (
+	// WatchPolicyRestart stops the current run, and starts a new one
+	WatchPolicyRestart WatchPolicy = "restart"
+
+	// WatchPolicyQueue lets the current run finish, before starting a new one
+	WatchPolicyQueue WatchPolicy = "queue"
+
+	// WatchPolicyIgnore drops changes, while a run is in progress
+	WatchPolicyIgnore WatchPolicy = "ignore"
+)
+
+var WatchPolicies = []WatchPolicy{WatchPolicyRestart, WatchPolicyQueue, WatchPolicyIgnore}
+
+// UnmarshalJSON implements custom unmarshaling for WatchPolicy
+func (p *WatchPolicy) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid watch policy format: %w", err)
+	}
+
+	switch WatchPolicy(s) {
+	case "", WatchPolicyRestart, WatchPolicyQueue, WatchPolicyIgnore:
+		*p = WatchPolicy(s)
+		return nil
+	}
+	return fmt.Errorf("invalid watch policy (%s), must be one of %v", s, WatchPolicies)
+}
+
+// TaskLog tees output of a task's commands to a file, without ANSI codes
+type TaskLog struct {
+	// File is path of the log file, `{{task}}` in it is replaced with task name.
+	// Relative paths are resolved against task's working directory
+	File string `json:"file"`
+
+	// Rotate is the number of previous log files to keep, as `<file>.1` ... `<file>.N`.
+	// With 0, log file is overwritten by every run of the task
+	Rotate int `json:"rotate,omitempty"`
 }
 
 type Task struct {
@@ -78,24 +169,96 @@ type Task struct {
 
 	Interactive bool `json:"interactive,omitempty"`
 
+	// Stdin of this task's commands, one of `inherit`, `none`, `file:<path>`, or any other text as is.
+	// `true` is short for `inherit`, and is how a task of a parallel group, is picked to get stdin of `run`.
+	// When not set, stdin of `run` is passed along, only if it is piped into, and commands are not running in parallel
+	Stdin *Stdin `json:"stdin,omitempty"`
+
+	// TTY runs commands of this task in a pseudo terminal (linux only), so that tools keep their colors
+	// and progress output. Stdout, and stderr of such commands are merged
+	TTY bool `json:"tty,omitempty"`
+
+	// Log tees stdout, and stderr of this task's commands to a file
+	Log *TaskLog `json:"log,omitempty"`
+
+	// Echo decides how commands of this task are shown before they run, one of box, line or none.
+	// When not set, it is inherited from the task that runs it, or from `run --echo`
+	Echo EchoMode `json:"echo,omitempty"`
+
+	// Color of this task's prefix, either a named color like `cyan`, or a hex color like `#ff8800`.
+	// When not set, it is picked from a palette, by hashing task name
+	Color Color `json:"color,omitempty"`
+
+	// Output decides how output of this task's commands is shown, one of prefixed, grouped, raw or quiet.
+	// When not set, it is inherited from the task that runs it, or from `run --output`
+	Output OutputMode `json:"output,omitempty"`
+
+	// IgnoreError, when true, lets the task continue past its failing commands,
+	// and does not fail the task itself
+	IgnoreError bool `json:"ignoreError,omitempty"`
+
+	// PassArgs, when true, forwards CLI args after `--` to the commands of this task,
+	// as "$@", and as `RUNFILE_ARGS` env var
+	PassArgs bool `json:"passArgs,omitempty"`
+
+	// Template, when true, renders commands of this task as go templates, with forwarded args
+	// as `{{ .Args }}`, and files changed in watch mode as `{{ .ChangedFiles }}`.
+	// It is off by default, as commands often have their own `{{ }}`, like `docker ps --format`
+	Template bool `json:"template,omitempty"`
+
 	// Parallel allows you to run commands
 	Parallel bool `json:"parallel"`
 
+	// FailFast, when true, cancels the remaining commands, or run targets of a parallel task,
+	// as soon as one of them fails. When false, all of them are waited for. Default: true
+	FailFast *bool `json:"failFast,omitempty"`
+
+	// MaxParallel limits how many commands, or run targets of a parallel task run at once
+	MaxParallel int `json:"maxParallel,omitempty"`
+
+	// Weight is the number of parallel slots (see `run -j`), each command of this task takes. Default: 1
+	Weight int `json:"weight,omitempty"`
+
+	// Priority decides which task's commands start first, when parallel slots are contended
+	Priority int `json:"priority,omitempty"`
+
 	// List of commands to be executed in given shell (default: sh)
 	// can take multiple forms
 	//   - simple string
 	//   - a json object with key
 	//       `run`, signifying other tasks to run
 	//       `if`, condition when to run this server
+	//       `defer`, a command that runs, only after all other commands of this task have finished
 	Commands []any `json:"cmd"`
+
+	// Finally commands always run after the commands of this task, irrespective of
+	// whether they succeeded, failed or got interrupted. They take the same forms as `cmd`,
+	// and get `RUNFILE_TASK_STATUS` (success|failure|cancelled) and `RUNFILE_EXIT_CODE` env vars
+	Finally []any `json:"finally,omitempty"`
 }
 
 type CommandJson struct {
 	Command *string `json:"cmd"`
 	Run     *string `json:"run"`
 
+	// Defer is a command, that runs after all other commands of the task, even if they fail
+	Defer *string `json:"defer,omitempty"`
+
 	Env EnvVar `json:"env"`
 
 	// If is a go template expression, which must evaluate to true, for task to run
 	If *string `json:"if,omitempty"`
+
+	// IgnoreError, when true, reports failure of this command, but does not stop the task
+	IgnoreError bool `json:"ignoreError,omitempty"`
+
+	// Echo decides how this command is shown before it runs, for a `run` target, it applies to
+	// commands of that task, unless the task sets its own
+	Echo EchoMode `json:"echo,omitempty"`
+
+	// Silent is short for `echo: none`
+	Silent bool `json:"silent,omitempty"`
+
+	// Stdin of this command, for a `run` target, it applies to commands of that task, unless the task sets its own
+	Stdin *Stdin `json:"stdin,omitempty"`
 }